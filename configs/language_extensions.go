@@ -7,4 +7,7 @@ import (
 var (
 	//go:embed language_extensions.json
 	JSONData []byte
+
+	//go:embed binary_extensions.json
+	BinaryExtensionsData []byte
 )
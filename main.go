@@ -1,19 +1,40 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
 	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"configs"
 )
@@ -21,13 +42,279 @@ import (
 type FlagInfo struct {
 	repository   string
 	revision     string
+	remote       string
+	allowClone   bool
+	revisionHash string
+	treeHash     string
+	reverseFrom  string
 	orderBy      string
+	orderKeys    []string
 	useCommitter bool
 	format       string
+	jsonWrap     bool
+	ratios       bool
+	color        string
+	barWidth     int
+	palette      string
+	progress     string
 	extensions   []string
 	languages    []string
 	exclude      []string
 	restrictTo   []string
+	maxDepth     int
+	excludeEmail []string
+	filesFrom    string
+	noFilter     bool
+	percentBase  string
+	repoLines    int
+	sanitize     bool
+	dropUnknown  bool
+	classify     bool
+	badgeLabel   string
+	minEdgeLines int
+	measurement  string
+	boundary     bool
+	noAlign      bool
+	collabReport bool
+	byExtension  bool
+	generated    []string
+	clusterMode  OptionalFlag
+	niceness     int
+	cpuProfile   string
+	memProfile   string
+	diffBase     string
+	diffHunks    map[string][]string
+	columns      []string
+	humanize     bool
+	verify       bool
+	ignoreRevs   []string
+	ignoreFiles  []string
+	aliases      map[string]string
+	locale       string
+	staleFiles   bool
+	staleAge     time.Duration
+	comments     map[string]*CommentSyntax
+	weights      map[string]float64
+	changedSince string
+	noIgnoreFile bool
+	metric       string
+	archive      string
+	byLanguage   bool
+	noCheckAttr  bool
+	rollupDepth  int
+	explainFile  string
+	version      bool
+	splitImport  bool
+	excludeRoot  bool
+	identity     string
+	phase        string
+	phaseStart   time.Time
+	hyperlinks   bool
+	commitSource string
+	decodeGitHub bool
+	diffReports  []string
+	detectCopies bool
+	dedupCopies  bool
+	filterMode   string
+	baseResult   string
+	changedFiles string
+	saveResult   string
+	base         *SavedResult
+	saved        *SavedResult
+	strict       bool
+	sparkline    bool
+	csvComma     rune
+	csvCRLF      bool
+	csvBOM       bool
+	retryOnLock  bool
+	output       string
+	alsoJSON     string
+	alsoCSV      string
+	showDirs     bool
+	showMerges   bool
+	lowMemory    bool
+	maxFileBytes int
+	maxFileLines int
+	sinceLastTag bool
+	showRepo     bool
+	repoName     string
+	ignoreCase   bool
+	clipboard    bool
+	excludeSelf  bool
+	selfName     string
+	selfEmail    string
+	logFormat    string
+	firstParent  bool
+	minPercent   float64
+	jsonSortKeys bool
+	noBinaryExcl bool
+	timeseries   []string
+	skipErrors   bool
+	top          int
+	topWithOther bool
+	repositories []string
+	jobs         int
+	quiet        bool
+	logger       *slog.Logger
+	rootCommits  map[string]bool
+	extLanguages map[string]string
+	assertions   []*Assertion
+	timeout      time.Duration
+	partial      bool
+	timedOut     bool
+
+	excludeRegex  []*regexp.Regexp
+	restrictRegex []*regexp.Regexp
+}
+
+var defaultGeneratedPatterns = []string{
+	"vendor", "node_modules", "*.min.js", "*.min.css", "*.pb.go", "*.pb.cc", "*.pb.h", "*_pb2.py", "*.generated.*",
+}
+
+type ListFlag []string
+
+func (lf *ListFlag) String() string {
+	return strings.Join(*lf, ";")
+}
+
+func (lf *ListFlag) Set(value string) error {
+	*lf = append(*lf, value)
+	return nil
+}
+
+type OptionalFlag string
+
+func (of *OptionalFlag) String() string {
+	return string(*of)
+}
+
+func (of *OptionalFlag) Set(value string) error {
+	*of = OptionalFlag(value)
+	return nil
+}
+
+func (of *OptionalFlag) IsBoolFlag() bool {
+	return true
+}
+
+const defaultIgnoreRevsFile = ".git-blame-ignore-revs"
+
+type ProgressEvent struct {
+	Phase   string `json:"phase,omitempty"`
+	Done    *int   `json:"done,omitempty"`
+	Total   *int   `json:"total,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func Report(fi *FlagInfo, level slog.Level, event *ProgressEvent, message string, attrs ...any) {
+	if !fi.logger.Enabled(context.Background(), level) {
+		return
+	}
+
+	if fi.progress == "json" {
+		if len(event.Phase) == 0 {
+			record := slog.NewRecord(time.Time{}, level, message, 0)
+			record.Add(attrs...)
+			record.Attrs(func(attr slog.Attr) bool {
+				event.Message += " " + attr.String()
+				return true
+			})
+			event.Message = message + event.Message
+		}
+
+		jsonData, err := json.Marshal(event)
+		if err != nil {
+			panic(err)
+		}
+		os.Stderr.WriteString(string(jsonData) + "\n")
+		return
+	}
+
+	fi.logger.Log(context.Background(), level, message, attrs...)
+}
+
+func ReportPhase(fi *FlagInfo, phase string) {
+	if len(fi.phase) > 0 {
+		ReportMessage(fi, slog.LevelDebug, "phase finished", "phase", fi.phase, "duration", time.Since(fi.phaseStart).Round(time.Millisecond))
+	}
+	fi.phase, fi.phaseStart = phase, time.Now()
+
+	Report(fi, slog.LevelInfo, &ProgressEvent{Phase: phase}, phase)
+}
+
+func ProgressPercent(done, total int) int {
+	if total == 0 || done >= total {
+		return 100
+	}
+	return min((done*100+total/2)/total, 99)
+}
+
+func ReportProgress(fi *FlagInfo, phase string, done, total int) {
+	Report(fi, slog.LevelInfo, &ProgressEvent{Phase: phase, Done: &done, Total: &total}, "progress",
+		"phase", phase, "done", done, "total", total, "percent", ProgressPercent(done, total))
+}
+
+func ReportMessage(fi *FlagInfo, level slog.Level, message string, attrs ...any) {
+	Report(fi, level, &ProgressEvent{}, message, attrs...)
+}
+
+func NewLogger(fi *FlagInfo, level string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	err := logLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, errors.New("unknown 'log-level' flag: " + level)
+	}
+	if fi.quiet {
+		logLevel = slog.LevelError
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	if fi.logFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+}
+
+type Assertion struct {
+	input  string
+	metric string
+	op     string
+	value  float64
+}
+
+var assertionOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+func ParseAssertion(input string) (*Assertion, error) {
+	for _, op := range assertionOps {
+		metric, valueInput, ok := strings.Cut(input, op)
+		if !ok {
+			continue
+		}
+
+		metric = strings.TrimSpace(metric)
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueInput), 64)
+		if err != nil || !CheckEntry(metric, []string{"top_author_lines_pct", "author_count", "bus_factor"}) {
+			break
+		}
+		return &Assertion{input: input, metric: metric, op: op, value: value}, nil
+	}
+	return nil, errors.New("invalid 'fail-if' flag: " + input)
+}
+
+func (a *Assertion) Holds(actual float64) bool {
+	switch a.op {
+	case ">=":
+		return actual >= a.value
+	case "<=":
+		return actual <= a.value
+	case "==":
+		return actual == a.value
+	case "!=":
+		return actual != a.value
+	case ">":
+		return actual > a.value
+	}
+	return actual < a.value
 }
 
 func CheckEntry(str string, arr []string) bool {
@@ -42,24 +329,417 @@ func CheckEntry(str string, arr []string) bool {
 func ParseFlag() (*FlagInfo, error) {
 	fi := new(FlagInfo)
 
-	var extensionsInput, languagesInput, excludeInput, restrictToInput string
+	var extensionsInput, languagesInput, excludeInput, restrictToInput, excludeEmailInput string
+	var excludeGenerated bool
+	var timeseriesInput string
+	var repositoriesInput string
+	var diffInput string
+	var columnsInput, staleInput, scoreInput, csvDelimiterInput, logLevelInput string
+	var mergeInput, excludeCommitInput, failIfInput, excludeRegexInput, restrictRegexInput ListFlag
+	var excludeCommitsFrom, ignoreRevsFile string
+	var generatedInput string
 	flag.StringVar(&fi.repository, "repository", ".", "repo path")
+	flag.BoolVar(&fi.allowClone, "allow-clone", false, "allow cloning remote repository")
+	flag.StringVar(&fi.archive, "archive", "", "analyze repository from tar archive")
 	flag.StringVar(&fi.revision, "revision", "HEAD", "commit ptr")
-	flag.StringVar(&fi.orderBy, "order-by", "lines", "sort key")
+	flag.BoolVar(&fi.sinceLastTag, "since-last-tag", false, "use the most recent tag as diff base")
+	flag.StringVar(&timeseriesInput, "timeseries", "", "sampling interval or revisions list for csv time series")
+	flag.StringVar(&fi.diffBase, "diff-base", "", "diff base commit ptr")
+	flag.StringVar(&fi.reverseFrom, "reverse-blame", "", "reverse blame start commit ptr")
+	flag.Var(&excludeCommitInput, "exclude-commit", "commit to ignore in blame")
+	flag.StringVar(&excludeCommitsFrom, "exclude-commits-from", "", "file with commits to ignore in blame")
+	flag.StringVar(&ignoreRevsFile, "ignore-revs-file", defaultIgnoreRevsFile, "git blame ignore revs file")
+	flag.StringVar(&fi.orderBy, "order-by", "lines", "sort keys list")
+	flag.StringVar(&fi.metric, "metric", "lines", "ownership size metric: lines or bytes")
+	flag.BoolVar(&fi.detectCopies, "detect-copies", false, "detect lines copied from other files")
+	flag.BoolVar(&fi.dedupCopies, "dedup-copied-lines", false, "credit each copied source line once")
+	flag.BoolVar(&fi.firstParent, "first-parent", false, "follow only first-parent history")
 	flag.BoolVar(&fi.useCommitter, "use-committer", false, "use committer")
+	flag.StringVar(&fi.identity, "identity", "name", "author identity: name, email or both")
+	flag.StringVar(&fi.commitSource, "commit-count-source", "blame", "commit count source: blame or log")
+	flag.Var(&mergeInput, "merge", "merge authors: Canonical=Alias1,Alias2")
+	flag.Var(&failIfInput, "fail-if", "fail when assertion holds: metric>value")
+	flag.BoolVar(&fi.boundary, "boundary-as-unknown", false, "attribute boundary commits to unknown author")
+	flag.BoolVar(&fi.splitImport, "split-initial-import", false, "attribute root commits to initial bucket")
+	flag.BoolVar(&fi.excludeRoot, "exclude-initial-commit", false, "exclude lines of root commits")
 	flag.StringVar(&fi.format, "format", "tabular", "output format")
+	flag.StringVar(&fi.progress, "progress-format", "text", "progress output format")
+	flag.StringVar(&fi.logFormat, "log-format", "text", "log format: text or json")
+	flag.StringVar(&logLevelInput, "log-level", "info", "log level: debug, info, warn or error")
+	flag.BoolVar(&fi.quiet, "quiet", false, "log errors only")
+	flag.BoolVar(&fi.humanize, "humanize", false, "group digits in tabular output")
+	flag.StringVar(&fi.locale, "locale", "en", "digit grouping locale")
+	flag.StringVar(&columnsInput, "columns", "name,lines,commits,files", "output columns list")
+	flag.StringVar(&scoreInput, "score", "", "composite score weights")
+	flag.BoolVar(&fi.showRepo, "show-repo", false, "add repository column to every row")
+	flag.StringVar(&fi.repoName, "repo-name", "", "repository name for the repository column")
+	flag.StringVar(&diffInput, "diff", "", "compare two json reports: old.json,new.json")
+	flag.StringVar(&repositoriesInput, "repositories", "", "repositories list to analyze together")
+	flag.IntVar(&fi.jobs, "jobs", runtime.NumCPU(), "repositories analyzed concurrently")
+	flag.BoolVar(&fi.showDirs, "show-directories", false, "show number of distinct directories per author")
+	flag.BoolVar(&fi.showMerges, "show-merges", false, "show number of merge commits per author")
+	flag.BoolVar(&fi.lowMemory, "low-memory", false, "keep per author counters only, no per file data")
+	flag.BoolVar(&fi.clipboard, "clipboard", false, "also copy output to system clipboard")
+	flag.StringVar(&fi.output, "output", "", "output file for binary formats")
+	flag.StringVar(&fi.alsoJSON, "also-json", "", "also write json output to file")
+	flag.StringVar(&fi.alsoCSV, "also-csv", "", "also write csv output to file")
+	flag.StringVar(&csvDelimiterInput, "csv-delimiter", ",", "csv field delimiter")
+	flag.BoolVar(&fi.csvCRLF, "csv-crlf", false, "use crlf line endings in csv")
+	flag.BoolVar(&fi.csvBOM, "csv-bom", false, "prepend utf-8 bom to csv")
+	flag.BoolVar(&fi.jsonWrap, "json-wrap", false, "wrap json output with metadata")
+	flag.BoolVar(&fi.ratios, "ratios", false, "add lines ratios to json output")
+	flag.IntVar(&fi.top, "top", 0, "show only top authors")
+	flag.BoolVar(&fi.topWithOther, "top-with-other", false, "aggregate authors beyond top into one row")
+	flag.BoolVar(&fi.jsonSortKeys, "json-sort-keys", false, "sort json object keys alphabetically")
+	flag.BoolVar(&fi.collabReport, "collaboration", false, "print per file authors report")
+	flag.BoolVar(&fi.byExtension, "by-extension", false, "print per extension lines report")
+	flag.BoolVar(&fi.version, "version", false, "print tool and git versions")
+	flag.StringVar(&fi.explainFile, "explain-file", "", "print blame attribution of a single file")
+	flag.IntVar(&fi.maxFileBytes, "max-file-bytes", 0, "skip files larger than bytes")
+	flag.IntVar(&fi.maxFileLines, "max-file-lines", 0, "skip files with more lines")
+	flag.IntVar(&fi.rollupDepth, "rollup-depth", 0, "print per directory report up to depth")
+	flag.BoolVar(&fi.byLanguage, "combine-by-language", false, "aggregate extension report by language")
+	flag.BoolVar(&fi.staleFiles, "stale-files", false, "print stale files report")
+	flag.StringVar(&staleInput, "stale-threshold", "1y", "stale file age")
+	flag.BoolVar(&fi.strict, "strict", false, "fail on shallow repositories")
+	flag.BoolVar(&fi.retryOnLock, "retry-on-lock", false, "retry git commands failing on repository locks")
+	flag.IntVar(&fi.niceness, "nice", 0, "git processes niceness")
+	flag.BoolVar(&fi.verify, "verify", false, "verify blamed line counts")
+	flag.StringVar(&fi.cpuProfile, "cpuprofile", "", "cpu profile path")
+	flag.StringVar(&fi.memProfile, "memprofile", "", "memory profile path")
+	flag.Var(&fi.clusterMode, "cluster-identities", "cluster similar identities: report or apply")
+	flag.BoolVar(&fi.decodeGitHub, "decode-github-noreply", false, "merge authors by github noreply email login")
+	flag.BoolVar(&fi.ignoreCase, "case-insensitive-authors", false, "merge author names differing only in case")
+	flag.BoolVar(&fi.sanitize, "sanitize-names", false, "replace invalid utf-8 in names")
+	flag.BoolVar(&fi.excludeSelf, "exclude-self", false, "exclude commits of the configured git user")
+	flag.BoolVar(&fi.dropUnknown, "drop-unknown", false, "exclude commits with empty author names")
+	flag.BoolVar(&fi.classify, "classify-lines", false, "classify lines as code, comment or blank")
+	flag.StringVar(&fi.color, "color", "auto", "colored output mode")
+	flag.IntVar(&fi.barWidth, "bar-width", 8, "percentage bar width")
+	flag.StringVar(&fi.palette, "palette", "hash", "author colors palette")
+	flag.BoolVar(&fi.sparkline, "sparkline", false, "show commit activity trend in terminal output")
+	flag.BoolVar(&fi.hyperlinks, "hyperlinks", false, "link author names in terminal output")
+	flag.BoolVar(&fi.noAlign, "no-align", false, "do not align tabular columns")
+	flag.StringVar(&fi.percentBase, "percent-base", "filtered", "percentage base")
+	flag.Float64Var(&fi.minPercent, "min-lines-percent", 0, "minimum percentage of lines for an author to be shown")
+	flag.StringVar(&fi.measurement, "measurement", "gitfame", "influx measurement name")
+	flag.StringVar(&fi.badgeLabel, "badge-label", "top contributor", "svg badge label")
+	flag.IntVar(&fi.minEdgeLines, "min-edge-lines", 1, "minimum author lines per file for dot graph edges")
 	flag.StringVar(&extensionsInput, "extensions", "", "extensions list")
 	flag.StringVar(&languagesInput, "languages", "", "languages list")
 	flag.StringVar(&excludeInput, "exclude", "", "exclude list")
 	flag.StringVar(&restrictToInput, "restrict-to", "", "restrict to list")
+	flag.BoolVar(&fi.noCheckAttr, "no-check-attr", false, "do not skip files marked non-text in gitattributes")
+	flag.BoolVar(&fi.noIgnoreFile, "no-ignore-file", false, "do not read "+ignoreFileName)
+	flag.Var(&excludeRegexInput, "exclude-regex", "regular expression of paths to exclude")
+	flag.Var(&restrictRegexInput, "restrict-regex", "regular expression of paths to restrict to")
+	flag.IntVar(&fi.maxDepth, "max-depth", -1, "max directory depth")
+	flag.BoolVar(&excludeGenerated, "exclude-generated", false, "exclude generated files")
+	flag.StringVar(&fi.filterMode, "filter-mode", "and", "combine extensions and languages filters: and or or")
+	flag.BoolVar(&fi.noBinaryExcl, "no-default-binary-exclude", false, "do not skip common binary extensions")
+	flag.StringVar(&generatedInput, "generated-patterns", strings.Join(defaultGeneratedPatterns, ","), "generated files patterns")
+	flag.StringVar(&excludeEmailInput, "exclude-email-domain", "", "exclude email domain list")
+	flag.DurationVar(&fi.timeout, "timeout", 0, "analysis timeout")
+	flag.BoolVar(&fi.partial, "partial-on-timeout", false, "output partial statistics on timeout")
+	flag.StringVar(&fi.baseResult, "base-result", "", "previous saved result to reuse")
+	flag.StringVar(&fi.changedFiles, "changed-files", "", "file list to re-blame over base result")
+	flag.StringVar(&fi.saveResult, "save-result", "", "save per file result for incremental runs")
+	flag.StringVar(&fi.changedSince, "changed-since", "", "only files changed since date")
+	flag.BoolVar(&fi.skipErrors, "skip-errors", false, "skip provided files missing at revision")
+	flag.StringVar(&fi.filesFrom, "files-from", "", "file list path")
+	flag.BoolVar(&fi.noFilter, "no-filter-provided", false, "do not filter provided files")
 	flag.Parse()
 
-	if !CheckEntry(fi.orderBy, []string{"lines", "commits", "files"}) {
-		return nil, errors.New("unknown 'order-by' flag: " + fi.orderBy)
+	if !CheckEntry(fi.metric, []string{"lines", "bytes"}) {
+		return nil, errors.New("unknown 'metric' flag: " + fi.metric)
+	}
+	for _, key := range strings.Split(fi.orderBy, ",") {
+		if !CheckEntry(key, []string{"lines", "commits", "files", "score", "bytes"}) || CheckEntry(key, fi.orderKeys) {
+			return nil, errors.New("invalid 'order-by' flag: " + fi.orderBy)
+		}
+		fi.orderKeys = append(fi.orderKeys, key)
+	}
+	for _, key := range []string{"lines", "commits", "files"} {
+		if !CheckEntry(key, fi.orderKeys) {
+			fi.orderKeys = append(fi.orderKeys, key)
+		}
 	}
-	if !CheckEntry(fi.format, []string{"tabular", "csv", "json", "json-lines"}) {
+	if !CheckEntry(fi.format, []string{"tabular", "csv", "json", "json-lines", "svg-badge", "plist", "influx", "org", "dot", "kv", "chart", "asciidoc", "confluence", "parquet", "sqlite"}) {
 		return nil, errors.New("unknown 'format' flag: " + fi.format)
 	}
+	binaryFormat := CheckEntry(fi.format, []string{"parquet", "sqlite"})
+	if binaryFormat && (len(fi.output) == 0 || fi.output == "-") {
+		return nil, errors.New("'format' flag " + fi.format + " requires 'output' flag with a file path")
+	}
+	if len(fi.output) > 0 && !binaryFormat {
+		return nil, errors.New("'output' flag requires 'format' flag parquet or sqlite")
+	}
+	if fi.clipboard && binaryFormat {
+		return nil, errors.New("'clipboard' flag does not support format: " + fi.format)
+	}
+	fi.ignoreRevs = excludeCommitInput
+	if len(excludeCommitsFrom) > 0 {
+		ignoreFile, err := filepath.Abs(excludeCommitsFrom)
+		if err != nil {
+			return nil, err
+		}
+		fi.ignoreFiles = append(fi.ignoreFiles, ignoreFile)
+	}
+
+	isIgnoreRevsSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "ignore-revs-file" {
+			isIgnoreRevsSet = true
+		}
+	})
+	if !isIgnoreRevsSet {
+		ignoreRevsFile = filepath.Join(fi.repository, defaultIgnoreRevsFile)
+		_, err := os.Stat(ignoreRevsFile)
+		if err != nil {
+			ignoreRevsFile = ""
+		}
+	}
+	if len(ignoreRevsFile) > 0 {
+		ignoreFile, err := filepath.Abs(ignoreRevsFile)
+		if err != nil {
+			return nil, err
+		}
+		fi.ignoreFiles = append(fi.ignoreFiles, ignoreFile)
+	}
+
+	fi.aliases = make(map[string]string)
+	for _, merge := range mergeInput {
+		canonical, aliases, ok := strings.Cut(merge, "=")
+		if !ok || len(canonical) == 0 || len(aliases) == 0 {
+			return nil, errors.New("invalid 'merge' flag: " + merge)
+		}
+		for _, alias := range strings.Split(aliases, ",") {
+			previous, ok := fi.aliases[alias]
+			if len(alias) == 0 || (ok && previous != canonical) {
+				return nil, errors.New("invalid 'merge' flag: " + merge)
+			}
+			fi.aliases[alias] = canonical
+		}
+	}
+
+	if len(scoreInput) > 0 {
+		fi.weights = make(map[string]float64)
+		for _, term := range strings.Split(scoreInput, ",") {
+			metric, weightInput, ok := strings.Cut(term, "=")
+			weight, err := strconv.ParseFloat(weightInput, 64)
+			_, seen := fi.weights[metric]
+			if !ok || err != nil || seen || !CheckEntry(metric, []string{"lines", "commits", "files"}) {
+				return nil, errors.New("invalid 'score' flag: " + term)
+			}
+			fi.weights[metric] = weight
+		}
+	}
+	if CheckEntry("score", fi.orderKeys) && fi.weights == nil {
+		return nil, errors.New("'order-by' flag score requires 'score' flag")
+	}
+	if CheckEntry("bytes", fi.orderKeys) && fi.metric != "bytes" {
+		return nil, errors.New("'order-by' flag bytes requires 'metric' flag bytes")
+	}
+
+	for _, input := range failIfInput {
+		assertion, err := ParseAssertion(input)
+		if err != nil {
+			return nil, err
+		}
+		fi.assertions = append(fi.assertions, assertion)
+	}
+
+	var err error
+	fi.staleAge, err = ParseAge(staleInput)
+	if err != nil {
+		return nil, errors.New("invalid 'stale-threshold' flag: " + staleInput)
+	}
+	delimiter := []rune(csvDelimiterInput)
+	if len(delimiter) != 1 || !utf8.ValidString(csvDelimiterInput) || strings.ContainsRune("\"\r\n\uFFFD", delimiter[0]) {
+		return nil, errors.New("invalid 'csv-delimiter' flag: " + csvDelimiterInput)
+	}
+	fi.csvComma = delimiter[0]
+
+	fi.columns = strings.Split(columnsInput, ",")
+	if fi.metric == "bytes" && !CheckEntry("bytes", fi.columns) {
+		fi.columns = append(fi.columns, "bytes")
+	}
+	if fi.showRepo && !CheckEntry("repo", fi.columns) {
+		fi.columns = append([]string{"repo"}, fi.columns...)
+	}
+	if len(diffInput) > 0 {
+		fi.diffReports = strings.Split(diffInput, ",")
+		if len(fi.diffReports) != 2 {
+			return nil, errors.New("'diff' flag requires two json reports: " + diffInput)
+		}
+		if !CheckEntry(fi.format, []string{"tabular", "csv", "json", "json-lines", "org", "kv", "asciidoc", "confluence"}) {
+			return nil, errors.New("'diff' flag does not support format: " + fi.format)
+		}
+		for _, column := range fi.columns {
+			if !CheckEntry(column, []string{"name", "lines", "commits", "files"}) {
+				return nil, errors.New("'diff' flag does not support column: " + column)
+			}
+		}
+		fi.color = "never"
+	}
+	if len(repositoriesInput) > 0 {
+		fi.repositories = strings.Split(repositoriesInput, ",")
+		if len(fi.archive) > 0 || len(fi.repoName) > 0 || len(fi.explainFile) > 0 || len(timeseriesInput) > 0 || fi.verify {
+			return nil, errors.New("'repositories' flag can not be used with 'archive', 'repo-name', 'explain-file', 'timeseries' or 'verify' flags")
+		}
+	}
+	if fi.jobs <= 0 {
+		return nil, errors.New("invalid 'jobs' flag: " + strconv.Itoa(fi.jobs))
+	}
+	if fi.showRepo && len(fi.repoName) == 0 {
+		fi.repoName, err = RepoName(fi.repository)
+		if err != nil {
+			return nil, err
+		}
+		if len(fi.archive) > 0 {
+			fi.repoName = fi.archive
+		}
+	}
+	if fi.showDirs && !CheckEntry("directories", fi.columns) {
+		fi.columns = append(fi.columns, "directories")
+	}
+	if fi.showMerges && !CheckEntry("merges", fi.columns) {
+		fi.columns = append(fi.columns, "merges")
+	}
+	if fi.weights != nil && !CheckEntry("score", fi.columns) {
+		fi.columns = append(fi.columns, "score")
+	}
+	for _, column := range fi.columns {
+		_, ok := columnTitles[column]
+		if !ok {
+			return nil, errors.New("unknown 'columns' flag: " + column)
+		}
+		if CheckEntry(column, []string{"code", "comment", "blank"}) && !fi.classify {
+			return nil, errors.New("'columns' flag " + column + " requires 'classify-lines' flag")
+		}
+		if column == "score" && fi.weights == nil {
+			return nil, errors.New("'columns' flag score requires 'score' flag")
+		}
+		if column == "repo" && !fi.showRepo {
+			return nil, errors.New("'columns' flag repo requires 'show-repo' flag")
+		}
+		if column == "directories" && !fi.showDirs {
+			return nil, errors.New("'columns' flag directories requires 'show-directories' flag")
+		}
+		if column == "merges" && !fi.showMerges {
+			return nil, errors.New("'columns' flag merges requires 'show-merges' flag")
+		}
+		if column == "bytes" && fi.metric != "bytes" {
+			return nil, errors.New("'columns' flag bytes requires 'metric' flag bytes")
+		}
+	}
+	_, ok := localeSeparators[fi.locale]
+	if !ok {
+		return nil, errors.New("unknown 'locale' flag: " + fi.locale)
+	}
+	if !CheckEntry(fi.progress, []string{"text", "json"}) {
+		return nil, errors.New("unknown 'progress-format' flag: " + fi.progress)
+	}
+	if !CheckEntry(fi.logFormat, []string{"text", "json"}) {
+		return nil, errors.New("unknown 'log-format' flag: " + fi.logFormat)
+	}
+	fi.logger, err = NewLogger(fi, logLevelInput)
+	if err != nil {
+		return nil, err
+	}
+	if !CheckEntry(fi.color, []string{"auto", "always", "never"}) {
+		return nil, errors.New("unknown 'color' flag: " + fi.color)
+	}
+	if !CheckEntry(fi.commitSource, []string{"blame", "log"}) {
+		return nil, errors.New("unknown 'commit-count-source' flag: " + fi.commitSource)
+	}
+	if fi.commitSource == "log" && len(fi.reverseFrom) > 0 {
+		return nil, errors.New("'commit-count-source' flag log can not be used with 'reverse-blame' flag")
+	}
+	if !CheckEntry(fi.filterMode, []string{"and", "or"}) {
+		return nil, errors.New("unknown 'filter-mode' flag: " + fi.filterMode)
+	}
+	if !CheckEntry(fi.identity, []string{"name", "email", "both"}) {
+		return nil, errors.New("unknown 'identity' flag: " + fi.identity)
+	}
+	if !CheckEntry(fi.percentBase, []string{"filtered", "repo"}) {
+		return nil, errors.New("unknown 'percent-base' flag: " + fi.percentBase)
+	}
+	if !CheckEntry(string(fi.clusterMode), []string{"", "false", "true", "report", "apply"}) {
+		return nil, errors.New("unknown 'cluster-identities' flag: " + string(fi.clusterMode))
+	}
+	if fi.clusterMode == "true" {
+		fi.clusterMode = "report"
+	}
+	if fi.clusterMode == "false" {
+		fi.clusterMode = ""
+	}
+	if len(fi.reverseFrom) > 0 && len(fi.diffBase) > 0 {
+		return nil, errors.New("'reverse-blame' flag can not be used with 'diff-base' flag")
+	}
+	if fi.niceness < 0 || fi.niceness > 19 {
+		return nil, errors.New("invalid 'nice' flag: " + strconv.Itoa(fi.niceness))
+	}
+	_, ok = palettes[fi.palette]
+	if !ok && fi.palette != "hash" {
+		return nil, errors.New("unknown 'palette' flag: " + fi.palette)
+	}
+	if fi.minPercent < 0 || fi.minPercent > 100 {
+		return nil, errors.New("invalid 'min-lines-percent' flag: " + strconv.FormatFloat(fi.minPercent, 'g', -1, 64))
+	}
+	if fi.top < 0 {
+		return nil, errors.New("invalid 'top' flag: " + strconv.Itoa(fi.top))
+	}
+	if fi.topWithOther && fi.top == 0 {
+		return nil, errors.New("'top-with-other' flag requires 'top' flag")
+	}
+	if fi.minEdgeLines <= 0 {
+		return nil, errors.New("invalid 'min-edge-lines' flag: " + strconv.Itoa(fi.minEdgeLines))
+	}
+	if fi.barWidth <= 0 {
+		return nil, errors.New("invalid 'bar-width' flag: " + strconv.Itoa(fi.barWidth))
+	}
+	if (len(fi.baseResult) > 0) != (len(fi.changedFiles) > 0) {
+		return nil, errors.New("'base-result' and 'changed-files' flags must be used together")
+	}
+	if (len(fi.baseResult) > 0 || len(fi.saveResult) > 0) && (len(fi.diffBase) > 0 || fi.sinceLastTag || len(fi.reverseFrom) > 0 || fi.dedupCopies || len(repositoriesInput) > 0 || len(timeseriesInput) > 0) {
+		return nil, errors.New("'base-result' and 'save-result' flags can not be used with 'diff-base', 'since-last-tag', 'reverse-blame', 'dedup-copied-lines', 'repositories' or 'timeseries' flags")
+	}
+	if fi.dedupCopies && !fi.detectCopies {
+		return nil, errors.New("'dedup-copied-lines' flag requires 'detect-copies' flag")
+	}
+	if fi.dedupCopies && fi.classify {
+		return nil, errors.New("'dedup-copied-lines' flag can not be used with 'classify-lines' flag")
+	}
+	if fi.lowMemory && (fi.collabReport || fi.staleFiles || fi.rollupDepth > 0 || fi.byExtension || fi.format == "dot" || fi.verify ||
+		fi.showDirs || fi.top > 0 || len(repositoriesInput) > 0 || fi.dedupCopies || len(fi.saveResult) > 0 ||
+		fi.ignoreCase || fi.decodeGitHub || fi.clusterMode == "apply") {
+		return nil, errors.New("'low-memory' flag can not be used with 'collaboration', 'stale-files', 'rollup-depth', 'by-extension', 'verify', 'show-directories', 'top', 'repositories', 'dedup-copied-lines', 'save-result', 'case-insensitive-authors', 'decode-github-noreply', 'cluster-identities=apply' or 'format=dot' flags")
+	}
+	if fi.excludeRoot && fi.splitImport {
+		return nil, errors.New("'exclude-initial-commit' flag conflicts with 'split-initial-import' flag")
+	}
+	if fi.sinceLastTag && len(fi.diffBase) > 0 {
+		return nil, errors.New("'since-last-tag' flag conflicts with 'diff-base' flag")
+	}
+	if len(timeseriesInput) > 0 {
+		fi.timeseries = strings.Split(timeseriesInput, ",")
+		if len(fi.diffBase) > 0 || fi.sinceLastTag || len(fi.reverseFrom) > 0 {
+			return nil, errors.New("'timeseries' flag can not be used with 'diff-base', 'since-last-tag' or 'reverse-blame' flags")
+		}
+	}
+	if fi.maxFileBytes < 0 {
+		return nil, errors.New("invalid 'max-file-bytes' flag: " + strconv.Itoa(fi.maxFileBytes))
+	}
+	if fi.maxFileLines < 0 {
+		return nil, errors.New("invalid 'max-file-lines' flag: " + strconv.Itoa(fi.maxFileLines))
+	}
+	if fi.rollupDepth < 0 {
+		return nil, errors.New("invalid 'rollup-depth' flag: " + strconv.Itoa(fi.rollupDepth))
+	}
+	if fi.maxDepth < -1 {
+		return nil, errors.New("invalid 'max-depth' flag: " + strconv.Itoa(fi.maxDepth))
+	}
 	if len(extensionsInput) > 0 {
 		fi.extensions = strings.Split(extensionsInput, ",")
 	}
@@ -72,426 +752,4461 @@ func ParseFlag() (*FlagInfo, error) {
 	if len(restrictToInput) > 0 {
 		fi.restrictTo = strings.Split(restrictToInput, ",")
 	}
+	for _, input := range excludeRegexInput {
+		re, err := regexp.Compile(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'exclude-regex' flag: %w", err)
+		}
+		fi.excludeRegex = append(fi.excludeRegex, re)
+	}
+	for _, input := range restrictRegexInput {
+		re, err := regexp.Compile(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'restrict-regex' flag: %w", err)
+		}
+		fi.restrictRegex = append(fi.restrictRegex, re)
+	}
+	if excludeGenerated && len(generatedInput) > 0 {
+		fi.generated = strings.Split(generatedInput, ",")
+	}
+	if len(excludeEmailInput) > 0 {
+		for _, domain := range strings.Split(excludeEmailInput, ",") {
+			fi.excludeEmail = append(fi.excludeEmail, strings.ToLower(strings.TrimPrefix(domain, "@")))
+		}
+	}
 
 	return fi, nil
 }
 
-type ExtensionInfo struct {
-	extension map[string]bool
-	language  map[string]bool
+func ParseAge(age string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'm': 30 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+
+	if len(age) < 2 {
+		return 0, errors.New("invalid age: " + age)
+	}
+	unit, ok := units[age[len(age)-1]]
+	if !ok {
+		return 0, errors.New("invalid age unit: " + age)
+	}
+	count, err := strconv.Atoi(age[:len(age)-1])
+	if err != nil || count < 0 {
+		return 0, errors.New("invalid age count: " + age)
+	}
+
+	return time.Duration(count) * unit, nil
 }
 
-func ParseExtension(fi *FlagInfo) (*ExtensionInfo, error) {
-	type Language struct {
-		Name       string   `json:"name"`
-		Type       string   `json:"type"`
-		Extensions []string `json:"extensions"`
+func GitCommand(ctx context.Context, fi *FlagInfo, args ...string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if fi.niceness > 0 {
+		cmd = exec.CommandContext(ctx, "nice", append([]string{"-n", strconv.Itoa(fi.niceness), "git"}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, "git", args...)
 	}
+	cmd.Dir = fi.repository
+	return cmd
+}
 
-	var languageData []Language
-	err := json.Unmarshal(configs.JSONData, &languageData)
+var version = "dev"
+
+func GitVersion() (string, error) {
+	res, err := exec.Command("git", "--version").Output()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	ei := &ExtensionInfo{extension: make(map[string]bool), language: make(map[string]bool)}
+	return strings.TrimPrefix(strings.TrimSpace(string(res)), "git version "), nil
+}
 
-	for _, e := range fi.extensions {
-		ei.extension[e] = true
+var lockErrors = []string{"Another git process seems to be running", ".lock': File exists"}
+
+func IsLockError(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
 	}
 
-	language := make(map[string]bool)
-	for _, l := range fi.languages {
-		language[l] = true
+	for _, message := range lockErrors {
+		if bytes.Contains(exitErr.Stderr, []byte(message)) {
+			return true
+		}
 	}
+	return false
+}
 
-	for _, l := range languageData {
-		_, ok := language[strings.ToLower(l.Name)]
-		if ok {
-			for _, e := range l.Extensions {
-				ei.language[e] = true
+func GitOutput(ctx context.Context, fi *FlagInfo, args ...string) ([]byte, error) {
+	const maxAttempts = 5
+	const initialBackoff = 100 * time.Millisecond
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		res, err := GitCommand(ctx, fi, args...).Output()
+		if err == nil || !fi.retryOnLock || attempt == maxAttempts || !IsLockError(err) {
+			return res, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func IsRemote(repository string) bool {
+	return strings.Contains(repository, "://") || strings.HasPrefix(repository, "git@")
+}
+
+const ignoreFileName = ".gitfameignore"
+
+func ReadIgnoreFile(fi *FlagInfo) error {
+	dir, err := filepath.Abs(fi.repository)
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := os.ReadFile(filepath.Join(dir, ignoreFileName))
+		if err == nil {
+			for _, line := range strings.Split(string(res), "\n") {
+				line = strings.TrimSpace(line)
+				if len(line) > 0 && !strings.HasPrefix(line, "#") {
+					fi.exclude = append(fi.exclude, line)
+				}
 			}
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
 		}
+		dir = parent
 	}
+}
 
-	return ei, nil
+func RepoName(repository string) (string, error) {
+	if IsRemote(repository) {
+		return repository, nil
+	}
+	return filepath.Abs(repository)
 }
 
-func (ei *ExtensionInfo) CheckName(fi *FlagInfo, name string) bool {
-	_, eOK := ei.extension[path.Ext(name)]
-	_, lOK := ei.language[path.Ext(name)]
+func CloneRepository(fi *FlagInfo) (func(), error) {
+	if !fi.allowClone {
+		return nil, errors.New("cloning remote repository requires 'allow-clone' flag: " + fi.repository)
+	}
 
-	return (len(fi.extensions) == 0 || eOK) && (len(fi.languages) == 0 || lOK)
+	dir, err := os.MkdirTemp("", "gitfame-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	fi.remote = fi.repository
+	fi.repository = dir
+
+	cmd := GitCommand(context.Background(), fi, "clone", "--bare", "--filter=blob:none", "--quiet", "--", fi.remote, ".")
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return cleanup, nil
 }
 
-func FindFiles(fi *FlagInfo, ei *ExtensionInfo) ([]string, error) {
-	cmd := exec.Command("git", "ls-tree", "--name-only", "-r", fi.revision)
-	cmd.Dir = fi.repository
-	res, err := cmd.Output()
+func ExtractArchive(fi *FlagInfo) (func(), error) {
+	file, err := os.Open(fi.archive)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReader(file)
+	magic, err := r.(*bufio.Reader).Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dir, err := os.MkdirTemp("", "gitfame-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	err = ExtractTar(tar.NewReader(r), dir)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("extracting archive %s: %w", fi.archive, err)
+	}
+
+	candidates := []string{dir}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		candidates = append(candidates, filepath.Join(dir, entries[0].Name()))
+	}
+	for _, candidate := range candidates {
+		stat, err := os.Stat(filepath.Join(candidate, ".git"))
+		if err == nil && stat.IsDir() {
+			fi.repository = candidate
+			return cleanup, nil
+		}
+	}
+
+	cleanup()
+	return nil, errors.New("archive has no .git directory, blame needs history: " + fi.archive)
+}
+
+func ExtractTar(tr *tar.Reader, dir string) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if !filepath.IsLocal(name) {
+			return errors.New("unsafe path in archive: " + header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(target), 0o755)
+			if err == nil {
+				err = WriteArchiveFile(target, tr, header.FileInfo().Mode().Perm())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func WriteArchiveFile(target string, r io.Reader, mode os.FileMode) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func ResolveSelf(fi *FlagInfo) error {
+	name, _ := GitOutput(context.Background(), fi, "config", "user.name")
+	email, _ := GitOutput(context.Background(), fi, "config", "user.email")
+
+	fi.selfName = strings.TrimSpace(string(name))
+	fi.selfEmail = strings.TrimSpace(string(email))
+	if len(fi.selfName) == 0 && len(fi.selfEmail) == 0 {
+		return errors.New("'exclude-self' flag requires git user.name or user.email to be configured")
+	}
+	return nil
+}
+
+func (ci *CommitInfo) Identity(fi *FlagInfo) string {
+	switch fi.identity {
+	case "email":
+		return ci.email
+	case "both":
+		if len(ci.email) == 0 {
+			return ci.author
+		}
+		return ci.author + " <" + ci.email + ">"
+	}
+	return ci.author
+}
+
+func (ci *CommitInfo) Normalize(fi *FlagInfo) bool {
+	if !ci.CheckEmail(fi) || (fi.excludeSelf && ci.IsSelf(fi)) || (fi.excludeRoot && fi.rootCommits[ci.commit]) {
+		return false
+	}
+	if fi.sanitize {
+		ci.author = strings.ToValidUTF8(ci.author, "\uFFFD")
+	}
+	ci.author = ci.Identity(fi)
+	if strings.TrimSpace(ci.author) == "" {
+		if fi.dropUnknown {
+			return false
+		}
+		ci.author = unknownAuthor
+	}
+	canonical, ok := fi.aliases[ci.author]
+	if ok {
+		ci.author = canonical
+	}
+	return true
+}
+
+func LogCommits(fi *FlagInfo, files []string, commitTimes map[string]int64) (map[string]map[string]bool, error) {
+	format := "--format=\x01%H%x00%an%x00%ae%x00%at"
+	if fi.useCommitter {
+		format = "--format=\x01%H%x00%cn%x00%ce%x00%ct"
+	}
+	args := []string{"-c", "core.quotePath=false", "log", format, "--name-only", "--no-renames"}
+	if fi.firstParent {
+		args = append(args, "--first-parent")
+	}
+	revision := fi.revisionHash
+	if len(fi.diffBase) > 0 {
+		revision = fi.diffBase + ".." + fi.revisionHash
+	}
+	res, err := GitOutput(context.Background(), fi, append(args, "--end-of-options", revision)...)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range files {
+		selected[name] = true
+	}
+
+	commitCount := make(map[string]map[string]bool)
+	var ci *CommitInfo
+	for _, line := range strings.Split(string(res), "\n") {
+		header, isHeader := strings.CutPrefix(line, "\x01")
+		if isHeader {
+			fields := strings.Split(header, "\x00")
+			if len(fields) != 4 {
+				return nil, errors.New("unexpected git log output: " + header)
+			}
+			timestamp, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			ci = &CommitInfo{commit: fields[0], author: fields[1], email: fields[2], time: timestamp}
+			commitTimes[ci.commit] = timestamp
+			if fi.splitImport && fi.rootCommits[ci.commit] {
+				ci.author, ci.email = boundaryAuthor, ""
+			}
+			if !ci.Normalize(fi) {
+				ci = nil
+			}
+			continue
+		}
+
+		if ci == nil || !selected[line] {
+			continue
+		}
+		_, ok := commitCount[ci.author]
+		if !ok {
+			commitCount[ci.author] = make(map[string]bool)
+		}
+		commitCount[ci.author][ci.commit] = true
+	}
+	return commitCount, nil
+}
+
+func MergeCommits(fi *FlagInfo) (map[string]map[string]bool, error) {
+	format := "--format=%H%x00%an%x00%ae"
+	if fi.useCommitter {
+		format = "--format=%H%x00%cn%x00%ce"
+	}
+	args := []string{"log", "--merges", format}
+	if fi.firstParent {
+		args = append(args, "--first-parent")
+	}
+	revision := fi.revisionHash
+	if len(fi.diffBase) > 0 {
+		revision = fi.diffBase + ".." + fi.revisionHash
+	}
+	res, err := GitOutput(context.Background(), fi, append(args, "--end-of-options", revision)...)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeCount := make(map[string]map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(string(res), "\n"), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 3 {
+			return nil, errors.New("unexpected git log output: " + line)
+		}
+
+		ci := &CommitInfo{commit: fields[0], author: fields[1], email: fields[2]}
+		if !ci.Normalize(fi) {
+			continue
+		}
+		_, ok := mergeCount[ci.author]
+		if !ok {
+			mergeCount[ci.author] = make(map[string]bool)
+		}
+		mergeCount[ci.author][ci.commit] = true
+	}
+	return mergeCount, nil
+}
+
+func (ci *CommitInfo) IsSelf(fi *FlagInfo) bool {
+	return (len(fi.selfName) > 0 && ci.author == fi.selfName) ||
+		(len(fi.selfEmail) > 0 && strings.EqualFold(ci.email, fi.selfEmail))
+}
+
+func ResolveCommit(fi *FlagInfo, revision string) (string, error) {
+	res, err := GitOutput(context.Background(), fi, "rev-parse", "--verify", "--end-of-options", revision+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(res)), nil
+}
+
+func ResolveRevision(fi *FlagInfo) error {
+	err := CheckShallow(fi)
+	if err != nil {
+		return err
+	}
+
+	fi.revisionHash, err = ResolveCommit(fi, fi.revision)
+	if err != nil {
+		return err
+	}
+
+	fi.treeHash = fi.revisionHash
+	if len(fi.reverseFrom) > 0 {
+		fi.treeHash, err = ResolveCommit(fi, fi.reverseFrom)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fi.splitImport || fi.excludeRoot {
+		return FindRootCommits(fi)
+	}
+	return nil
+}
+
+func CheckShallow(fi *FlagInfo) error {
+	res, err := GitOutput(context.Background(), fi, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(res)) != "true" {
+		return nil
+	}
+
+	const message = "repository is a shallow clone, lines of missing history are attributed to the oldest available commits; run 'git fetch --unshallow'"
+	if fi.strict {
+		return errors.New(message)
+	}
+	ReportMessage(fi, slog.LevelWarn, message)
+	return nil
+}
+
+func FindRootCommits(fi *FlagInfo) error {
+	res, err := GitOutput(context.Background(), fi, "rev-list", "--max-parents=0", "--end-of-options", fi.revisionHash)
+	if err != nil {
+		return err
+	}
+
+	fi.rootCommits = make(map[string]bool)
+	for _, commit := range strings.Fields(string(res)) {
+		fi.rootCommits[commit] = true
+	}
+	return nil
+}
+
+func FindLastTag(fi *FlagInfo) (string, error) {
+	res, err := GitOutput(context.Background(), fi, "describe", "--tags", "--abbrev=0", fi.revisionHash)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", errors.New("no tags found before revision " + fi.revisionHash)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(res)), nil
+}
+
+func FindDiffHunks(fi *FlagInfo) error {
+	res, err := GitOutput(context.Background(), fi, "diff", "-U0", "--no-color", "--no-ext-diff", "--end-of-options", fi.diffBase, fi.revisionHash, "--")
+	if err != nil {
+		return err
+	}
+
+	fi.diffHunks = make(map[string][]string)
+
+	name := ""
+	for _, line := range strings.Split(string(res), "\n") {
+		if strings.HasPrefix(line, "+++ ") {
+			name = ""
+			if line != "+++ /dev/null" {
+				name = strings.TrimPrefix(line, "+++ b/")
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "@@ ") || len(name) == 0 {
+			continue
+		}
+
+		newRange := strings.Fields(line)[2][1:]
+		start, count := newRange, "1"
+		if i := strings.Index(newRange, ","); i != -1 {
+			start, count = newRange[:i], newRange[i+1:]
+		}
+
+		startLine, err := strconv.Atoi(start)
+		if err != nil {
+			return err
+		}
+		lineCount, err := strconv.Atoi(count)
+		if err != nil {
+			return err
+		}
+		if lineCount == 0 {
+			continue
+		}
+
+		fi.diffHunks[name] = append(fi.diffHunks[name], fmt.Sprintf("%d,%d", startLine, startLine+lineCount-1))
+	}
+
+	return nil
+}
+
+type CommentSyntax struct {
+	line       string
+	blockStart string
+	blockEnd   string
+}
+
+type LineClasses struct {
+	Code    int `json:"code"`
+	Comment int `json:"comment"`
+	Blank   int `json:"blank"`
+}
+
+func (lc *LineClasses) Add(other *LineClasses) {
+	lc.Code += other.Code
+	lc.Comment += other.Comment
+	lc.Blank += other.Blank
+}
+
+type LineClassifier struct {
+	syntax  *CommentSyntax
+	inBlock bool
+}
+
+func (lc *LineClassifier) Classify(content string, classes *LineClasses) {
+	line := strings.TrimSpace(content)
+	if len(line) == 0 {
+		classes.Blank++
+		return
+	}
+	if lc.syntax == nil {
+		classes.Code++
+		return
+	}
+
+	cs := lc.syntax
+	if lc.inBlock {
+		classes.Comment++
+		lc.inBlock = !strings.Contains(line, cs.blockEnd)
+		return
+	}
+	if len(cs.line) > 0 && strings.HasPrefix(line, cs.line) {
+		classes.Comment++
+		return
+	}
+	if len(cs.blockStart) > 0 && strings.HasPrefix(line, cs.blockStart) {
+		classes.Comment++
+		lc.inBlock = !strings.Contains(line[len(cs.blockStart):], cs.blockEnd)
+		return
+	}
+
+	classes.Code++
+	if len(cs.blockStart) > 0 {
+		i := strings.LastIndex(line, cs.blockStart)
+		lc.inBlock = i != -1 && !strings.Contains(line[i+len(cs.blockStart):], cs.blockEnd)
+	}
+}
+
+type ExtensionInfo struct {
+	extension map[string]bool
+	language  map[string]bool
+	binary    map[string]bool
+}
+
+func ParseExtension(fi *FlagInfo) (*ExtensionInfo, error) {
+	type Language struct {
+		Name         string   `json:"name"`
+		Type         string   `json:"type"`
+		LineComment  string   `json:"line_comment"`
+		BlockComment []string `json:"block_comment"`
+		Extensions   []string `json:"extensions"`
+	}
+
+	var languageData []Language
+	err := json.Unmarshal(configs.JSONData, &languageData)
+	if err != nil {
+		return nil, err
+	}
+
+	ei := &ExtensionInfo{extension: make(map[string]bool), language: make(map[string]bool), binary: make(map[string]bool)}
+
+	if !fi.noBinaryExcl && len(fi.extensions) == 0 && len(fi.languages) == 0 {
+		var binaryExtensions []string
+		err = json.Unmarshal(configs.BinaryExtensionsData, &binaryExtensions)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range binaryExtensions {
+			ei.binary[e] = true
+		}
+	}
+
+	for _, e := range fi.extensions {
+		ei.extension[e] = true
+	}
+
+	language := make(map[string]bool)
+	for _, l := range fi.languages {
+		language[l] = true
+	}
+
+	for _, l := range languageData {
+		_, ok := language[strings.ToLower(l.Name)]
+		if ok {
+			for _, e := range l.Extensions {
+				ei.language[e] = true
+			}
+		}
+	}
+
+	if fi.byLanguage {
+		fi.extLanguages = make(map[string]string)
+		for _, l := range languageData {
+			for _, e := range l.Extensions {
+				_, ok := fi.extLanguages[e]
+				if !ok {
+					fi.extLanguages[e] = l.Name
+				}
+			}
+		}
+	}
+
+	if fi.classify {
+		fi.comments = make(map[string]*CommentSyntax)
+		for _, l := range languageData {
+			if len(l.LineComment) == 0 && len(l.BlockComment) != 2 {
+				continue
+			}
+
+			cs := &CommentSyntax{line: l.LineComment}
+			if len(l.BlockComment) == 2 {
+				cs.blockStart, cs.blockEnd = l.BlockComment[0], l.BlockComment[1]
+			}
+			for _, e := range l.Extensions {
+				_, ok := fi.comments[e]
+				if !ok {
+					fi.comments[e] = cs
+				}
+			}
+		}
+	}
+
+	return ei, nil
+}
+
+func (ei *ExtensionInfo) CheckName(fi *FlagInfo, name string) bool {
+	_, eOK := ei.extension[path.Ext(name)]
+	_, lOK := ei.language[path.Ext(name)]
+	if ei.binary[strings.ToLower(path.Ext(name))] {
+		return false
+	}
+
+	if fi.filterMode == "or" && len(fi.extensions) > 0 && len(fi.languages) > 0 {
+		return eOK || lOK
+	}
+	return (len(fi.extensions) == 0 || eOK) && (len(fi.languages) == 0 || lOK)
+}
+
+func ReadFileList(source string) ([]string, error) {
+	var res []byte
+	var err error
+	if source == "-" {
+		res, err = io.ReadAll(os.Stdin)
+	} else {
+		res, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(res), "\n") {
+		name = strings.TrimSuffix(name, "\r")
+		if len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func CheckFileList(fi *FlagInfo, names []string) ([]string, error) {
+	tree, err := ListTree(fi)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool)
+	for _, name := range tree {
+		present[name] = true
+	}
+
+	var found, missing []string
+	for _, name := range names {
+		if present[name] {
+			found = append(found, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 && !fi.skipErrors {
+		return nil, errors.New("files not found at revision " + fi.treeHash + ": " + strings.Join(missing, ", "))
+	}
+	for _, name := range missing {
+		ReportMessage(fi, slog.LevelWarn, "skipping file missing at revision", "file", name, "revision", fi.treeHash)
+	}
+	return found, nil
+}
+
+func ListTree(fi *FlagInfo) ([]string, error) {
+	res, err := GitOutput(context.Background(), fi, "ls-tree", "--name-only", "-r", "--end-of-options", fi.treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	names := strings.Split(string(res), "\n")
+	return names[:len(names)-1], nil
+}
+
+func ListFiles(fi *FlagInfo) ([]string, error) {
+	if len(fi.filesFrom) > 0 {
+		names, err := ReadFileList(fi.filesFrom)
+		if err != nil {
+			return nil, err
+		}
+		return CheckFileList(fi, names)
+	}
+
+	if len(fi.diffBase) > 0 {
+		var names []string
+		for name := range fi.diffHunks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return names, nil
+	}
+
+	return ListTree(fi)
+}
+
+func FindChangedFiles(fi *FlagInfo) (map[string]bool, error) {
+	args := []string{"log", "--since=" + fi.changedSince, "--name-only", "--format="}
+	if fi.firstParent {
+		args = append(args, "--first-parent")
+	}
+	res, err := GitOutput(context.Background(), fi, append(args, "--end-of-options", fi.revisionHash)...)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for _, name := range strings.Split(string(res), "\n") {
+		if len(name) > 0 {
+			changed[name] = true
+		}
+	}
+	return changed, nil
+}
+
+func FindFiles(fi *FlagInfo, ei *ExtensionInfo) ([]string, error) {
+	names, err := ListFiles(fi)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fi.changedSince) > 0 {
+		changed, err := FindChangedFiles(fi)
+		if err != nil {
+			return nil, err
+		}
+
+		var changedNames []string
+		for _, name := range names {
+			if changed[name] {
+				changedNames = append(changedNames, name)
+			}
+		}
+		names = changedNames
+	}
+
+	if len(fi.filesFrom) > 0 && fi.noFilter {
+		return names, nil
+	}
+
+	CheckName := func(name string) (bool, error) {
+		if !ei.CheckName(fi, name) {
+			return false, nil
+		}
+
+		if fi.maxDepth != -1 && strings.Count(name, "/") > fi.maxDepth {
+			return false, nil
+		}
+
+		for _, pattern := range fi.generated {
+			for _, segment := range strings.Split(name, "/") {
+				matched, err := path.Match(pattern, segment)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return false, nil
+				}
+			}
+		}
+
+		for _, pattern := range fi.exclude {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return false, nil
+			}
+		}
+
+		for _, re := range fi.excludeRegex {
+			if re.MatchString(name) {
+				return false, nil
+			}
+		}
+		if len(fi.restrictRegex) > 0 {
+			isMatched := false
+			for _, re := range fi.restrictRegex {
+				if re.MatchString(name) {
+					isMatched = true
+					break
+				}
+			}
+			if !isMatched {
+				return false, nil
+			}
+		}
+
+		isFound := (len(fi.restrictTo) == 0)
+		for _, pattern := range fi.restrictTo {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return false, nil
+			}
+			if matched {
+				isFound = true
+				break
+			}
+		}
+
+		return isFound, nil
+	}
+
+	var files []string
+	for _, name := range names {
+		isAllowed, err := CheckName(name)
+		if err != nil {
+			return nil, err
+		}
+		if isAllowed {
+			files = append(files, name)
+		}
+	}
+
+	if !fi.noCheckAttr && len(files) > 0 {
+		files, err = ExcludeNonText(fi, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (fi.maxFileBytes > 0 || fi.maxFileLines > 0) && len(files) > 0 {
+		files, err = ExcludeLarge(fi, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(fi.generated) > 0 {
+		return ExcludeMinified(fi, files)
+	}
+
+	return files, nil
+}
+
+const minifiedLineLen = 500
+
+func ExcludeNonText(fi *FlagInfo, files []string) ([]string, error) {
+	cmd := GitCommand(context.Background(), fi, "check-attr", "-z", "--stdin", "text")
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\x00") + "\x00")
+	res, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	nonText := make(map[string]bool)
+	fields := strings.Split(string(res), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+2] == "unset" {
+			nonText[fields[i]] = true
+		}
+	}
+
+	var textFiles []string
+	for _, name := range files {
+		if !nonText[name] {
+			textFiles = append(textFiles, name)
+		}
+	}
+	return textFiles, nil
+}
+
+func ExcludeLarge(fi *FlagInfo, files []string) ([]string, error) {
+	large := make(map[string]bool)
+
+	if fi.maxFileBytes > 0 {
+		selected := make(map[string]bool)
+		for _, name := range files {
+			selected[name] = true
+		}
+
+		res, err := GitOutput(context.Background(), fi, "ls-tree", "-r", "-l", "-z", "--end-of-options", fi.treeHash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range strings.Split(string(res), "\x00") {
+			info, name, ok := strings.Cut(entry, "\t")
+			fields := strings.Fields(info)
+			if !ok || len(fields) < 4 {
+				continue
+			}
+			size, err := strconv.Atoi(fields[3])
+			if err == nil && selected[name] && size > fi.maxFileBytes {
+				large[name] = true
+				ReportMessage(fi, slog.LevelInfo, "skipping large file", "file", name, "bytes", size)
+			}
+		}
+	}
+
+	if fi.maxFileLines > 0 {
+		var objects []string
+		for _, name := range files {
+			if !large[name] {
+				objects = append(objects, fi.treeHash+":"+name)
+			}
+		}
+
+		err := ReadBlobs(fi, objects, func(object string, content []byte) {
+			lines := CountLines(content)
+			if lines > fi.maxFileLines {
+				name := object[len(fi.treeHash)+1:]
+				large[name] = true
+				ReportMessage(fi, slog.LevelInfo, "skipping large file", "file", name, "lines", lines)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var smallFiles []string
+	for _, name := range files {
+		if !large[name] {
+			smallFiles = append(smallFiles, name)
+		}
+	}
+	return smallFiles, nil
+}
+
+func ExcludeMinified(fi *FlagInfo, files []string) ([]string, error) {
+	var objects []string
+	for _, name := range files {
+		if CheckEntry(path.Ext(name), []string{".js", ".css"}) {
+			objects = append(objects, fi.treeHash+":"+name)
+		}
+	}
+
+	minified := make(map[string]bool)
+	err := ReadBlobs(fi, objects, func(object string, content []byte) {
+		for _, line := range bytes.Split(content, []byte("\n")) {
+			if len(line) > minifiedLineLen {
+				minified[object[len(fi.treeHash)+1:]] = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, name := range files {
+		if !minified[name] {
+			res = append(res, name)
+		}
+	}
+
+	return res, nil
+}
+
+func ReadBlobs(fi *FlagInfo, objects []string, handle func(object string, content []byte)) error {
+	cmd := GitCommand(context.Background(), fi, "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	res, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(bytes.NewReader(res))
+	for _, object := range objects {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(header)
+		if fields[len(fields)-1] == "missing" {
+			continue
+		}
+		size, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return err
+		}
+
+		content := make([]byte, size+1)
+		_, err = io.ReadFull(r, content)
+		if err != nil {
+			return err
+		}
+
+		handle(object, content[:size])
+	}
+
+	return nil
+}
+
+func CountRepoLines(fi *FlagInfo) error {
+	res, err := GitOutput(context.Background(), fi, "ls-tree", "-r", "--end-of-options", fi.treeHash)
+	if err != nil {
+		return err
+	}
+
+	var blobs []string
+	for _, entry := range strings.Split(string(res), "\n") {
+		fields := strings.Fields(entry)
+		if len(fields) >= 3 && fields[1] == "blob" {
+			blobs = append(blobs, fields[2])
+		}
+	}
+
+	return ReadBlobs(fi, blobs, func(object string, content []byte) {
+		fi.repoLines += CountLines(content)
+	})
+}
+
+func CountLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+func VerifyLines(fi *FlagInfo, fileData FileData) error {
+	expected := make(map[string]int)
+
+	var objects []string
+	for name := range fileData {
+		if len(fi.diffBase) > 0 {
+			for _, hunk := range fi.diffHunks[name] {
+				start, end, _ := strings.Cut(hunk, ",")
+				startLine, _ := strconv.Atoi(start)
+				endLine, _ := strconv.Atoi(end)
+				expected[name] += endLine - startLine + 1
+			}
+		} else {
+			objects = append(objects, fi.treeHash+":"+name)
+		}
+	}
+
+	err := ReadBlobs(fi, objects, func(object string, content []byte) {
+		expected[object[len(fi.treeHash)+1:]] = CountLines(content)
+	})
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range fileData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fileData[name].lines != expected[name] {
+			ReportMessage(fi, slog.LevelWarn, "verification failed",
+				"file", name, "blamed", fileData[name].lines, "expected", expected[name])
+		}
+	}
+
+	return nil
+}
+
+const commitLen = 40
+
+const boundaryAuthor = "(initial)"
+
+const unknownAuthor = "(unknown)"
+
+type LineOrigin struct {
+	key   string
+	bytes int
+}
+
+type CommitInfo struct {
+	commit    string
+	author    string
+	email     string
+	time      int64
+	lineCount int
+	byteCount int
+	classes   LineClasses
+	origins   []LineOrigin
+}
+
+func (ci *CommitInfo) CheckEmail(fi *FlagInfo) bool {
+	domain := strings.ToLower(ci.email[strings.LastIndex(ci.email, "@")+1:])
+	return !CheckEntry(domain, fi.excludeEmail)
+}
+
+func ParseHeader(line, key string) (string, error) {
+	value, ok := strings.CutPrefix(line, key+" ")
+	if !ok {
+		return "", errors.New("unexpected git output line, expected '" + key + "': " + line)
+	}
+	return value, nil
+}
+
+func ParseIdentity(identity string) (string, string, error) {
+	i := strings.LastIndex(identity, " <")
+	j := strings.LastIndex(identity, ">")
+	if i == -1 || j < i {
+		return "", "", errors.New("unexpected git identity: " + identity)
+	}
+	return identity[:i], identity[i+2 : j], nil
+}
+
+func AnalyzeEmptyFile(ctx context.Context, fi *FlagInfo, name string) (*CommitInfo, error) {
+	args := []string{"log", "-n", "1", "--format=raw"}
+	if fi.firstParent {
+		args = append(args, "--first-parent")
+	}
+	res, err := GitOutput(ctx, fi, append(args, "--end-of-options", fi.revisionHash, "--", name)...)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(res), "\n")
+	commit, err := ParseHeader(lines[0], "commit")
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	for _, line := range lines[1:] {
+		if len(line) == 0 {
+			break
+		}
+		key, value, _ := strings.Cut(line, " ")
+		headers[key] = value
+	}
+
+	key := "author"
+	if fi.useCommitter {
+		key = "committer"
+	}
+	identity, ok := headers[key]
+	if !ok {
+		return nil, errors.New("missing '" + key + "' in git log output for " + name)
+	}
+	author, email, err := ParseIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	date := strings.Fields(identity[strings.LastIndex(identity, ">")+1:])
+	if len(date) == 0 {
+		return nil, errors.New("unexpected git identity: " + identity)
+	}
+	timestamp, err := strconv.ParseInt(date[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if fi.splitImport && fi.rootCommits[commit] {
+		author, email = boundaryAuthor, ""
+	}
+
+	return &CommitInfo{
+		commit:    commit,
+		author:    author,
+		email:     email,
+		time:      timestamp,
+		lineCount: 0,
+	}, nil
+}
+
+func AnalyzeFile(ctx context.Context, fi *FlagInfo, name string) (map[string]*CommitInfo, error) {
+	commits := make(map[string]*CommitInfo)
+
+	args := []string{"blame", "--porcelain"}
+	if fi.detectCopies {
+		args = append(args, "-C")
+	}
+	if fi.firstParent {
+		args = append(args, "--first-parent")
+	}
+	for _, rev := range fi.ignoreRevs {
+		args = append(args, "--ignore-rev", rev)
+	}
+	for _, ignoreFile := range fi.ignoreFiles {
+		args = append(args, "--ignore-revs-file", ignoreFile)
+	}
+	if len(fi.diffBase) > 0 {
+		hunks := fi.diffHunks[name]
+		if len(hunks) == 0 {
+			return commits, nil
+		}
+
+		for _, hunk := range hunks {
+			args = append(args, "-L", hunk)
+		}
+	}
+	if len(fi.reverseFrom) > 0 {
+		args = append(args, "--reverse", fi.treeHash+".."+fi.revisionHash, "--", name)
+	} else {
+		args = append(args, fi.revisionHash, "--", name)
+	}
+
+	res, err := GitOutput(ctx, fi, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(res), "\n")
+	lines = lines[:len(lines)-1]
+
+	if len(lines) == 0 {
+		ci, err := AnalyzeEmptyFile(ctx, fi, name)
+		if err != nil {
+			return nil, err
+		}
+
+		commits[ci.commit] = ci
+		return commits, nil
+	}
+
+	key := "author"
+	if fi.useCommitter {
+		key = "committer"
+	}
+
+	classifier := &LineClassifier{syntax: fi.comments[path.Ext(name)]}
+	origin := ""
+
+	for i := 0; i < len(lines); i++ {
+		header := strings.Fields(lines[i])
+		if len(header) < 3 || len(header[0]) != commitLen {
+			return nil, errors.New("unexpected git blame output line for " + name + ": " + lines[i])
+		}
+		commit := header[0]
+
+		headers := make(map[string]string)
+		for i++; i < len(lines) && !strings.HasPrefix(lines[i], "\t"); i++ {
+			key, value, _ := strings.Cut(lines[i], " ")
+			headers[key] = value
+		}
+		if i == len(lines) {
+			return nil, errors.New("missing line content in git blame output for " + name)
+		}
+
+		filename, ok := headers["filename"]
+		if ok {
+			origin = filename
+		}
+		var lineOrigin LineOrigin
+		if fi.dedupCopies {
+			lineOrigin = LineOrigin{key: commit + "\x00" + origin + "\x00" + header[1], bytes: len(lines[i]) - 1}
+		}
+
+		ci, ok := commits[commit]
+		if ok {
+			ci.lineCount++
+			ci.byteCount += len(lines[i]) - 1
+			if fi.classify {
+				classifier.Classify(lines[i][1:], &ci.classes)
+			}
+			if fi.dedupCopies {
+				ci.origins = append(ci.origins, lineOrigin)
+			}
+			continue
+		}
+
+		author, aOK := headers[key]
+		email, eOK := headers[key+"-mail"]
+		date, tOK := headers[key+"-time"]
+		if !aOK || !eOK || !tOK {
+			return nil, errors.New("missing '" + key + "' in git blame output for " + name)
+		}
+		timestamp, err := strconv.ParseInt(date, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		ci = &CommitInfo{
+			commit:    commit,
+			author:    author,
+			email:     strings.TrimSuffix(strings.TrimPrefix(email, "<"), ">"),
+			time:      timestamp,
+			lineCount: 1,
+			byteCount: len(lines[i]) - 1,
+		}
+		_, isBoundary := headers["boundary"]
+		if (isBoundary && fi.boundary) || (fi.splitImport && fi.rootCommits[commit]) {
+			ci.author = boundaryAuthor
+			ci.email = ""
+		}
+		if fi.classify {
+			classifier.Classify(lines[i][1:], &ci.classes)
+		}
+		if fi.dedupCopies {
+			ci.origins = append(ci.origins, lineOrigin)
+		}
+		commits[commit] = ci
+	}
+
+	return commits, nil
+}
+
+type AuthorInfo struct {
+	Repo    string `json:"repo,omitempty"`
+	Name    string `json:"name"`
+	Email   string `json:"-"`
+	Commits int    `json:"commits"`
+	Lines   int    `json:"lines"`
+	Files   int    `json:"files"`
+	Bytes   *int   `json:"bytes,omitempty"`
+
+	Directories *int `json:"directories,omitempty"`
+	Merges      *int `json:"merges,omitempty"`
+
+	LinesRatio  *float64     `json:"lines_ratio,omitempty"`
+	LineClasses *LineClasses `json:"line_classes,omitempty"`
+	Score       *float64     `json:"score,omitempty"`
+
+	fileSet     map[string]bool
+	commitSet   map[string]bool
+	mergeSet    map[string]bool
+	commitTimes []int64
+}
+
+const othersAuthor = "(others)"
+
+var columnTitles = map[string]string{
+	"name":    "Name",
+	"email":   "Email",
+	"lines":   "Lines",
+	"commits": "Commits",
+	"files":   "Files",
+	"code":    "Code",
+	"comment": "Comment",
+	"blank":   "Blank",
+	"score":   "Score",
+	"bytes":   "Bytes",
+
+	"directories": "Directories",
+	"merges":      "Merges",
+	"repo":        "Repo",
+}
+
+func (ai *AuthorInfo) Column(column string) string {
+	switch column {
+	case "repo":
+		return ai.Repo
+	case "name":
+		return ai.Name
+	case "email":
+		return ai.Email
+	case "lines":
+		return strconv.Itoa(ai.Lines)
+	case "commits":
+		return strconv.Itoa(ai.Commits)
+	case "files":
+		return strconv.Itoa(ai.Files)
+	case "directories":
+		if ai.Directories == nil {
+			return "0"
+		}
+		return strconv.Itoa(*ai.Directories)
+	case "merges":
+		if ai.Merges == nil {
+			return "0"
+		}
+		return strconv.Itoa(*ai.Merges)
+	case "bytes":
+		if ai.Bytes == nil {
+			return "0"
+		}
+		return strconv.Itoa(*ai.Bytes)
+	case "score":
+		if ai.Score == nil {
+			return "0"
+		}
+		return strconv.FormatFloat(*ai.Score, 'f', -1, 64)
+	}
+
+	classes := ai.LineClasses
+	if classes == nil {
+		classes = &LineClasses{}
+	}
+	switch column {
+	case "code":
+		return strconv.Itoa(classes.Code)
+	case "comment":
+		return strconv.Itoa(classes.Comment)
+	case "blank":
+		return strconv.Itoa(classes.Blank)
+	}
+	return ""
+}
+
+func (ai *AuthorInfo) Columns(fi *FlagInfo) []string {
+	var row []string
+	for _, column := range fi.columns {
+		row = append(row, ai.Column(column))
+	}
+	return row
+}
+
+var localeSeparators = map[string]string{
+	"en": ",",
+	"ru": "\u00a0",
+	"fr": "\u202f",
+	"de": ".",
+	"ch": "'",
+}
+
+func GroupDigits(number, separator string) string {
+	var b strings.Builder
+	for i, digit := range number {
+		if i > 0 && (len(number)-i)%3 == 0 {
+			b.WriteString(separator)
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+func HumanizeRow(fi *FlagInfo, row []string) {
+	for i, column := range fi.columns {
+		if CheckEntry(column, []string{"lines", "commits", "files", "bytes", "directories", "merges", "code", "comment", "blank"}) {
+			row[i] = GroupDigits(row[i], localeSeparators[fi.locale])
+		}
+	}
+}
+
+func ColumnTitles(fi *FlagInfo) []string {
+	var row []string
+	for _, column := range fi.columns {
+		row = append(row, columnTitles[column])
+	}
+	return row
+}
+
+type AuthorData []*AuthorInfo
+
+type FileInfo struct {
+	authors  map[string]int
+	lines    int
+	modified int64
+}
+
+type FileData map[string]*FileInfo
+
+func PrimaryEmail(emails map[string]int) string {
+	primary := ""
+	for email, lines := range emails {
+		if len(primary) == 0 || lines > emails[primary] || (lines == emails[primary] && email < primary) {
+			primary = email
+		}
+	}
+	return primary
+}
+
+func NormalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func NoreplyClusters(authorEmails map[string]map[string]int) map[string]string {
+	clusters := make(map[string]string)
+	for author, emails := range authorEmails {
+		noreply := make(map[string]int)
+		for email, lines := range emails {
+			_, ok := GitHubLogin(email)
+			if ok {
+				noreply[email] = lines
+			}
+		}
+		if len(noreply) > 0 {
+			clusters[author], _ = GitHubLogin(PrimaryEmail(noreply))
+		}
+	}
+	return clusters
+}
+
+func ClusterIdentities(authorEmails map[string]map[string]int, lineCount map[string]int) map[string]string {
+	parent := make(map[string]string)
+	for author := range authorEmails {
+		parent[author] = author
+	}
+
+	var find func(author string) string
+	find = func(author string) string {
+		if parent[author] != author {
+			parent[author] = find(parent[author])
+		}
+		return parent[author]
+	}
+
+	owners := make(map[string]string)
+	link := func(key, author string) {
+		owner, ok := owners[key]
+		if ok {
+			parent[find(author)] = find(owner)
+		} else {
+			owners[key] = author
+		}
+	}
+
+	for author, emails := range authorEmails {
+		if name := NormalizeName(author); len(name) > 0 {
+			link("name "+name, author)
+		}
+		for email := range emails {
+			if len(email) > 0 {
+				link("email "+strings.ToLower(email), author)
+			}
+		}
+	}
+
+	canonical := make(map[string]string)
+	for author := range parent {
+		root := find(author)
+		c, ok := canonical[root]
+		if !ok || lineCount[author] > lineCount[c] || (lineCount[author] == lineCount[c] && author < c) {
+			canonical[root] = author
+		}
+	}
+
+	clusters := make(map[string]string)
+	for author := range parent {
+		clusters[author] = canonical[find(author)]
+	}
+
+	return clusters
+}
+
+func ReportClusters(fi *FlagInfo, clusters map[string]string) {
+	aliases := make(map[string][]string)
+	for author, canonical := range clusters {
+		aliases[canonical] = append(aliases[canonical], author)
+	}
+
+	var canonicals []string
+	for canonical, authors := range aliases {
+		if len(authors) > 1 {
+			canonicals = append(canonicals, canonical)
+		}
+	}
+	sort.Strings(canonicals)
+
+	for _, canonical := range canonicals {
+		sort.Strings(aliases[canonical])
+		ReportMessage(fi, slog.LevelInfo, "heuristic identity cluster",
+			"canonical", canonical, "aliases", strings.Join(aliases[canonical], ", "))
+	}
+}
+
+func ExplainFile(fi *FlagInfo, name string) error {
+	commits, err := AnalyzeFile(context.Background(), fi, name)
+	if err != nil {
+		return err
+	}
+
+	var infos []*CommitInfo
+	for _, ci := range commits {
+		infos = append(infos, ci)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].lineCount != infos[j].lineCount {
+			return infos[i].lineCount > infos[j].lineCount
+		}
+		return infos[i].commit < infos[j].commit
+	})
+
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintln(tw, "Commit\tAuthor\tEmail\tDate\tLines\tCounted")
+	totalLines := 0
+	for _, ci := range infos {
+		counted := "yes"
+		if !ci.CheckEmail(fi) {
+			counted = "no (excluded email)"
+		} else if fi.excludeSelf && ci.IsSelf(fi) {
+			counted = "no (self)"
+		} else if fi.excludeRoot && fi.rootCommits[ci.commit] {
+			counted = "no (initial commit)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", ci.commit, ci.author, ci.email,
+			time.Unix(ci.time, 0).UTC().Format(time.RFC3339), ci.lineCount, counted)
+		totalLines += ci.lineCount
+	}
+	fmt.Fprintf(tw, "total\t\t\t\t%d\t\n", totalLines)
+
+	return tw.Flush()
+}
+
+func CaseClusters(lineCount map[string]int) map[string]string {
+	canonical := make(map[string]string)
+	for author, lines := range lineCount {
+		key := strings.ToLower(author)
+		current, ok := canonical[key]
+		if !ok || lines > lineCount[current] || (lines == lineCount[current] && author < current) {
+			canonical[key] = author
+		}
+	}
+
+	clusters := make(map[string]string)
+	for author := range lineCount {
+		clusters[author] = canonical[strings.ToLower(author)]
+	}
+	return clusters
+}
+
+type SavedCommit struct {
+	Commit  string      `json:"commit"`
+	Author  string      `json:"author"`
+	Email   string      `json:"email"`
+	Time    int64       `json:"time"`
+	Lines   int         `json:"lines"`
+	Bytes   int         `json:"bytes"`
+	Classes LineClasses `json:"classes"`
+}
+
+type SavedResult struct {
+	Revision string                   `json:"revision"`
+	Files    map[string][]SavedCommit `json:"files"`
+}
+
+func ReadSavedResult(fi *FlagInfo) error {
+	content, err := os.ReadFile(fi.baseResult)
+	if err != nil {
+		return err
+	}
+
+	fi.base = &SavedResult{}
+	err = json.Unmarshal(content, fi.base)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fi.baseResult, err)
+	}
+
+	names, err := ReadFileList(fi.changedFiles)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		delete(fi.base.Files, name)
+	}
+	return nil
+}
+
+func WriteSavedResult(fi *FlagInfo) error {
+	jsonData, err := json.Marshal(fi.saved)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fi.saveResult, jsonData, 0o644)
+}
+
+func SaveCommits(commits map[string]*CommitInfo) []SavedCommit {
+	saved := []SavedCommit{}
+	for _, ci := range commits {
+		saved = append(saved, SavedCommit{
+			Commit:  ci.commit,
+			Author:  ci.author,
+			Email:   ci.email,
+			Time:    ci.time,
+			Lines:   ci.lineCount,
+			Bytes:   ci.byteCount,
+			Classes: ci.classes,
+		})
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		return saved[i].Commit < saved[j].Commit
+	})
+	return saved
+}
+
+func LoadCommits(saved []SavedCommit) map[string]*CommitInfo {
+	commits := make(map[string]*CommitInfo)
+	for _, sc := range saved {
+		commits[sc.Commit] = &CommitInfo{
+			commit:    sc.Commit,
+			author:    sc.Author,
+			email:     sc.Email,
+			time:      sc.Time,
+			lineCount: sc.Lines,
+			byteCount: sc.Bytes,
+			classes:   sc.Classes,
+		}
+	}
+	return commits
+}
+
+func DedupCopies(analyzed map[string]map[string]*CommitInfo, aggregate func(string, map[string]*CommitInfo)) map[string]int {
+	var names []string
+	for name := range analyzed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]bool)
+	fileLines := make(map[string]int)
+	for _, name := range names {
+		commits := analyzed[name]
+		for commit, ci := range commits {
+			fileLines[name] += ci.lineCount
+			for _, origin := range ci.origins {
+				if seen[origin.key] {
+					ci.lineCount--
+					ci.byteCount -= origin.bytes
+				}
+				seen[origin.key] = true
+			}
+			if ci.lineCount == 0 && len(ci.origins) > 0 {
+				delete(commits, commit)
+			}
+		}
+		aggregate(name, commits)
+	}
+	return fileLines
+}
+
+func CollectStatistics(fi *FlagInfo, files []string) (AuthorData, FileData, error) {
+	fileData := make(FileData)
+	fileCount := make(map[string]map[string]bool)
+	fileTotal := make(map[string]int)
+	commitCount := make(map[string]map[string]bool)
+	lineCount := make(map[string]int)
+	classCount := make(map[string]*LineClasses)
+	byteCount := make(map[string]int)
+	authorEmails := make(map[string]map[string]int)
+	commitTimes := make(map[string]int64)
+
+	phase := "collecting statistics"
+	if len(fi.repositories) > 0 {
+		phase += " (" + fi.repoName + ")"
+	}
+
+	baseCtx := context.Background()
+	if fi.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		baseCtx, cancelTimeout = context.WithTimeout(baseCtx, fi.timeout)
+		defer cancelTimeout()
+	}
+
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+
+	var firstErr error
+	errOnce := sync.Once{}
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	blamed := files
+	doneCount := atomic.Int64{}
+	progressMu := sync.Mutex{}
+	lastPercent := -1
+	reportDone := func() {
+		done := int(doneCount.Add(1))
+		total := len(blamed)
+		percent := ProgressPercent(done, total)
+
+		progressMu.Lock()
+		defer progressMu.Unlock()
+
+		if percent > lastPercent {
+			lastPercent = percent
+			ReportProgress(fi, phase, done, total)
+		}
+	}
+
+	aggregated := 0
+	if len(fi.saveResult) > 0 {
+		fi.saved = &SavedResult{Revision: fi.revisionHash, Files: make(map[string][]SavedCommit)}
+	}
+
+	aggregate := func(name string, commits map[string]*CommitInfo) {
+		if fi.saved != nil {
+			fi.saved.Files[name] = SaveCommits(commits)
+		}
+
+		info := &FileInfo{authors: make(map[string]int)}
+		aggregated++
+		if !fi.lowMemory {
+			fileData[name] = info
+		}
+		for _, ci := range commits {
+			info.lines += ci.lineCount
+		}
+		for _, ci := range commits {
+			if !ci.Normalize(fi) {
+				continue
+			}
+
+			_, ok := info.authors[ci.author]
+			if !ok {
+				fileTotal[ci.author]++
+			}
+			if !fi.lowMemory {
+				_, ok = fileCount[ci.author]
+				if !ok {
+					fileCount[ci.author] = make(map[string]bool)
+				}
+				fileCount[ci.author][name] = true
+			}
+
+			_, ok = commitCount[ci.author]
+			if !ok {
+				commitCount[ci.author] = make(map[string]bool)
+			}
+			commitCount[ci.author][ci.commit] = true
+			if fi.sparkline {
+				commitTimes[ci.commit] = ci.time
+			}
+
+			lineCount[ci.author] += ci.lineCount
+			byteCount[ci.author] += ci.byteCount
+			_, ok = classCount[ci.author]
+			if !ok {
+				classCount[ci.author] = &LineClasses{}
+			}
+			classCount[ci.author].Add(&ci.classes)
+			info.authors[ci.author] += ci.lineCount
+			info.modified = max(info.modified, ci.time)
+
+			_, ok = authorEmails[ci.author]
+			if !ok {
+				authorEmails[ci.author] = make(map[string]int)
+			}
+			authorEmails[ci.author][ci.email] += ci.lineCount
+		}
+	}
+
+	if fi.base != nil {
+		blamed = nil
+		for _, name := range files {
+			saved, ok := fi.base.Files[name]
+			if ok {
+				aggregate(name, LoadCommits(saved))
+			} else {
+				blamed = append(blamed, name)
+			}
+		}
+		ReportMessage(fi, slog.LevelInfo, "reusing base result", "reused", len(files)-len(blamed), "blamed", len(blamed))
+	}
+
+	analyzed := make(map[string]map[string]*CommitInfo)
+	wg.Add(len(blamed))
+	for i := range blamed {
+		name := blamed[i]
+
+		go func() {
+			defer wg.Done()
+			defer func() {
+				r := recover()
+				if r != nil {
+					fail(fmt.Errorf("analyzing %s: %v", name, r))
+				}
+			}()
+
+			commits, err := AnalyzeFile(ctx, fi, name)
+			if err != nil {
+				if errors.Is(baseCtx.Err(), context.DeadlineExceeded) {
+					if fi.partial {
+						return
+					}
+					err = baseCtx.Err()
+				}
+				fail(fmt.Errorf("analyzing %s: %w", name, err))
+				return
+			}
+
+			defer reportDone()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if fi.dedupCopies {
+				analyzed[name] = commits
+				return
+			}
+			aggregate(name, commits)
+		}()
+	}
+
+	wg.Wait()
+
+	if len(blamed) == 0 {
+		ReportProgress(fi, phase, 0, 0)
+	}
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	if fi.dedupCopies {
+		for name, lines := range DedupCopies(analyzed, aggregate) {
+			fileData[name].lines = lines
+		}
+		analyzed = nil
+	}
+	if fi.partial && errors.Is(baseCtx.Err(), context.DeadlineExceeded) {
+		fi.timedOut = true
+		ReportMessage(fi, slog.LevelWarn, "timeout reached", "analyzed", aggregated, "total", len(files))
+	}
+
+	if fi.commitSource == "log" {
+		logCommits, err := LogCommits(fi, files, commitTimes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for author := range lineCount {
+			commitCount[author] = logCommits[author]
+			if commitCount[author] == nil {
+				commitCount[author] = make(map[string]bool)
+			}
+		}
+	}
+
+	mergeCount := make(map[string]map[string]bool)
+	if fi.showMerges {
+		var err error
+		mergeCount, err = MergeCommits(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if fi.clusterMode == "report" {
+		ReportClusters(fi, ClusterIdentities(authorEmails, lineCount))
+	}
+	mergeAuthors := func(clusters map[string]string) {
+		for author, canonical := range clusters {
+			if author == canonical {
+				continue
+			}
+			_, ok := fileCount[canonical]
+			if !ok {
+				fileCount[canonical] = make(map[string]bool)
+				commitCount[canonical] = make(map[string]bool)
+				classCount[canonical] = &LineClasses{}
+				authorEmails[canonical] = make(map[string]int)
+			}
+
+			for name := range fileCount[author] {
+				fileCount[canonical][name] = true
+			}
+			fileTotal[canonical] = len(fileCount[canonical])
+			for commit := range commitCount[author] {
+				commitCount[canonical][commit] = true
+			}
+			if mergeCount[author] != nil && mergeCount[canonical] == nil {
+				mergeCount[canonical] = make(map[string]bool)
+			}
+			for commit := range mergeCount[author] {
+				mergeCount[canonical][commit] = true
+			}
+			lineCount[canonical] += lineCount[author]
+			byteCount[canonical] += byteCount[author]
+			classCount[canonical].Add(classCount[author])
+			for email, lines := range authorEmails[author] {
+				authorEmails[canonical][email] += lines
+			}
+			for _, info := range fileData {
+				lines, ok := info.authors[author]
+				if ok {
+					info.authors[canonical] += lines
+					delete(info.authors, author)
+				}
+			}
+
+			delete(fileCount, author)
+			delete(fileTotal, author)
+			delete(commitCount, author)
+			delete(mergeCount, author)
+			delete(lineCount, author)
+			delete(byteCount, author)
+			delete(classCount, author)
+			delete(authorEmails, author)
+		}
+	}
+
+	if fi.decodeGitHub {
+		mergeAuthors(NoreplyClusters(authorEmails))
+	}
+	if fi.ignoreCase {
+		mergeAuthors(CaseClusters(lineCount))
+	}
+	if fi.clusterMode == "apply" {
+		mergeAuthors(ClusterIdentities(authorEmails, lineCount))
+	}
+
+	var authorData AuthorData
+	for author := range lineCount {
+		ai := &AuthorInfo{
+			Name:    author,
+			Email:   PrimaryEmail(authorEmails[author]),
+			Commits: len(commitCount[author]),
+			Lines:   lineCount[author],
+			Files:   fileTotal[author],
+
+			fileSet:   fileCount[author],
+			commitSet: commitCount[author],
+		}
+		if fi.sparkline {
+			for commit := range commitCount[author] {
+				ai.commitTimes = append(ai.commitTimes, commitTimes[commit])
+			}
+		}
+		if fi.classify {
+			ai.LineClasses = classCount[author]
+		}
+		if fi.metric == "bytes" {
+			bytes := byteCount[author]
+			ai.Bytes = &bytes
+		}
+		if fi.showRepo {
+			ai.Repo = fi.repoName
+		}
+		if fi.showDirs {
+			dirs := make(map[string]bool)
+			for name := range fileCount[author] {
+				dirs[path.Dir(name)] = true
+			}
+			dirCount := len(dirs)
+			ai.Directories = &dirCount
+		}
+		if fi.showMerges {
+			ai.mergeSet = mergeCount[author]
+			merges := len(mergeCount[author])
+			ai.Merges = &merges
+		}
+		authorData = append(authorData, ai)
+	}
+
+	return authorData, fileData, nil
+}
+
+func (ad AuthorData) Len() int {
+	return len(ad)
+}
+
+func (ad AuthorData) Swap(i, j int) {
+	ad[i], ad[j] = ad[j], ad[i]
+}
+
+var keys = []string{"lines", "commits", "files"}
+
+func (ai *AuthorInfo) SortValue(key string) float64 {
+	switch key {
+	case "commits":
+		return float64(ai.Commits)
+	case "files":
+		return float64(ai.Files)
+	case "score":
+		return *ai.Score
+	case "bytes":
+		return float64(*ai.Bytes)
+	}
+	return float64(ai.Lines)
+}
+
+func (ad AuthorData) Less(i, j int) bool {
+	for _, key := range keys {
+		iVal, jVal := ad[i].SortValue(key), ad[j].SortValue(key)
+		if iVal != jVal {
+			return iVal > jVal
+		}
+	}
+	return ad[i].Name < ad[j].Name
+}
+
+func SortData(fi *FlagInfo, authorData AuthorData) {
+	keys = fi.orderKeys
+	sort.Sort(authorData)
+}
+
+func FilterMinPercent(fi *FlagInfo, authorData AuthorData) AuthorData {
+	totalLines := TotalLines(fi, authorData)
+	if totalLines == 0 {
+		return authorData
+	}
+
+	var filtered AuthorData
+	for _, ai := range authorData {
+		if float64(ai.Lines)*100 >= fi.minPercent*float64(totalLines) {
+			filtered = append(filtered, ai)
+		}
+	}
+	return filtered
+}
+
+func TruncateTop(fi *FlagInfo, authorData AuthorData) AuthorData {
+	if len(authorData) <= fi.top {
+		return authorData
+	}
+	if !fi.topWithOther {
+		return authorData[:fi.top]
+	}
+
+	others := &AuthorInfo{Name: othersAuthor, fileSet: make(map[string]bool), commitSet: make(map[string]bool), mergeSet: make(map[string]bool)}
+	if fi.showRepo {
+		others.Repo = fi.repoName
+	}
+	if fi.classify {
+		others.LineClasses = &LineClasses{}
+	}
+	if fi.metric == "bytes" {
+		others.Bytes = new(int)
+	}
+
+	for _, ai := range authorData[fi.top:] {
+		others.Lines += ai.Lines
+		for name := range ai.fileSet {
+			others.fileSet[name] = true
+		}
+		for commit := range ai.commitSet {
+			others.commitSet[commit] = true
+		}
+		for commit := range ai.mergeSet {
+			others.mergeSet[commit] = true
+		}
+		others.commitTimes = append(others.commitTimes, ai.commitTimes...)
+		if fi.classify {
+			others.LineClasses.Add(ai.LineClasses)
+		}
+		if fi.metric == "bytes" {
+			*others.Bytes += *ai.Bytes
+		}
+	}
+	others.Files = len(others.fileSet)
+	others.Commits = len(others.commitSet)
+
+	if fi.showDirs {
+		dirs := make(map[string]bool)
+		for name := range others.fileSet {
+			dirs[path.Dir(name)] = true
+		}
+		dirCount := len(dirs)
+		others.Directories = &dirCount
+	}
+	if fi.showMerges {
+		merges := len(others.mergeSet)
+		others.Merges = &merges
+	}
+	if fi.weights != nil {
+		ComputeScores(fi, AuthorData{others})
+	}
+
+	return append(authorData[:fi.top:fi.top], others)
+}
+
+func UseColor(fi *FlagInfo) bool {
+	if fi.color != "auto" {
+		return fi.color == "always"
+	}
+
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func ComputeScores(fi *FlagInfo, authorData AuthorData) {
+	for _, ai := range authorData {
+		score := fi.weights["lines"]*float64(ai.Lines) +
+			fi.weights["commits"]*float64(ai.Commits) +
+			fi.weights["files"]*float64(ai.Files)
+		ai.Score = &score
+	}
+}
+
+func ComputeRatios(authorData AuthorData) {
+	totalLines := 0
+	for _, ai := range authorData {
+		totalLines += ai.Lines
+	}
+
+	for _, ai := range authorData {
+		ratio := 0.0
+		if totalLines > 0 {
+			ratio = float64(ai.Lines) / float64(totalLines)
+		}
+		ai.LinesRatio = &ratio
+	}
+}
+
+func TotalLines(fi *FlagInfo, authorData AuthorData) int {
+	if fi.percentBase == "repo" {
+		return fi.repoLines
+	}
+
+	totalLines := 0
+	for _, ai := range authorData {
+		totalLines += ai.Lines
+	}
+	return totalLines
+}
+
+var palettes = map[string][][3]uint8{
+	"tableau": {
+		{0x4e, 0x79, 0xa7}, {0xf2, 0x8e, 0x2b}, {0xe1, 0x57, 0x59}, {0x76, 0xb7, 0xb2}, {0x59, 0xa1, 0x4f},
+		{0xed, 0xc9, 0x48}, {0xb0, 0x7a, 0xa1}, {0xff, 0x9d, 0xa7}, {0x9c, 0x75, 0x5f}, {0xba, 0xb0, 0xac},
+	},
+	"okabe-ito": {
+		{0xe6, 0x9f, 0x00}, {0x56, 0xb4, 0xe9}, {0x00, 0x9e, 0x73}, {0xf0, 0xe4, 0x42},
+		{0x00, 0x72, 0xb2}, {0xd5, 0x5e, 0x00}, {0xcc, 0x79, 0xa7}, {0x99, 0x99, 0x99},
+	},
+}
+
+func AuthorColor(fi *FlagInfo, author string) [3]uint8 {
+	h := fnv.New32a()
+	h.Write([]byte(author))
+	hash := h.Sum32()
+
+	palette, ok := palettes[fi.palette]
+	if ok {
+		return palette[hash%uint32(len(palette))]
+	}
+
+	const saturation, lightness = 0.6, 0.5
+	hue := float64(hash % 360)
+	c := (1 - math.Abs(2*lightness-1)) * saturation
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := lightness - c/2
+
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = c, x, 0
+	case hue < 120:
+		r, g, b = x, c, 0
+	case hue < 180:
+		r, g, b = 0, c, x
+	case hue < 240:
+		r, g, b = 0, x, c
+	case hue < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return [3]uint8{uint8(math.Round((r + m) * 255)), uint8(math.Round((g + m) * 255)), uint8(math.Round((b + m) * 255))}
+}
+
+func RenderBar(color [3]uint8, lines, totalLines, width int) string {
+	share := 0.0
+	if totalLines > 0 {
+		share = float64(lines) / float64(totalLines)
+	}
+	filled := int(math.Round(share * float64(width)))
+
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m%s %.1f%%", color[0], color[1], color[2],
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled), share*100)
+}
+
+func TerminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+func WriteChart(fi *FlagInfo, authorData AuthorData) error {
+	const minBarWidth = 10
+	const eighths = " ▏▎▍▌▋▊▉█"
+
+	stat, err := os.Stdout.Stat()
+	isTerminal := err == nil && stat.Mode()&os.ModeCharDevice != 0
+	showColor := isTerminal && UseColor(fi)
+
+	nameWidth, valueWidth, topLines := 0, 0, 0
+	for _, ai := range authorData {
+		nameWidth = max(nameWidth, len([]rune(ai.Name)))
+		valueWidth = max(valueWidth, len(strconv.Itoa(ai.Lines)))
+		topLines = max(topLines, ai.Lines)
+	}
+	barWidth := max(TerminalWidth()-nameWidth-valueWidth-2, minBarWidth)
+
+	var b strings.Builder
+	for _, ai := range authorData {
+		share := 0.0
+		if topLines > 0 {
+			share = float64(ai.Lines) / float64(topLines)
+		}
+
+		var bar string
+		if isTerminal {
+			units := int(math.Round(share * float64(barWidth*8)))
+			bar = strings.Repeat("█", units/8)
+			if units%8 > 0 {
+				bar += string([]rune(eighths)[units%8])
+			}
+		} else {
+			bar = strings.Repeat("#", int(math.Round(share*float64(barWidth))))
+		}
+		padding := strings.Repeat(" ", barWidth-len([]rune(bar)))
+		if showColor {
+			color := AuthorColor(fi, ai.Name)
+			bar = fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", color[0], color[1], color[2], bar)
+		}
+
+		fmt.Fprintf(&b, "%s%s %s%s %*d\n", ai.Name, strings.Repeat(" ", nameWidth-len([]rune(ai.Name))),
+			bar, padding, valueWidth, ai.Lines)
+	}
+
+	_, err = os.Stdout.WriteString(b.String())
+	return err
+}
+
+func GitHubLogin(email string) (string, bool) {
+	const noreplyDomain = "@users.noreply.github.com"
+
+	login, ok := strings.CutSuffix(strings.ToLower(email), noreplyDomain)
+	if !ok || len(login) == 0 {
+		return "", false
+	}
+	_, after, found := strings.Cut(login, "+")
+	if found {
+		login = after
+	}
+	return login, len(login) > 0
+}
+
+const sparkBuckets = 12
+
+func Sparkline(times []int64, from, to int64) string {
+	const levels = " ▁▂▃▄▅▆▇█"
+
+	counts := make([]int, sparkBuckets)
+	for _, t := range times {
+		bucket := 0
+		if to > from {
+			bucket = min(int((t-from)*sparkBuckets/(to-from)), sparkBuckets-1)
+		}
+		counts[bucket]++
+	}
+
+	top := slices.Max(counts)
+	var b strings.Builder
+	for _, count := range counts {
+		level := 0
+		if count > 0 {
+			level = max(1, int(math.Round(float64(count)/float64(top)*8)))
+		}
+		b.WriteRune([]rune(levels)[level])
+	}
+	return b.String()
+}
+
+func Sparklines(authorData AuthorData) map[*AuthorInfo]string {
+	from, to := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, ai := range authorData {
+		for _, t := range ai.commitTimes {
+			from, to = min(from, t), max(to, t)
+		}
+	}
+
+	sparklines := make(map[*AuthorInfo]string)
+	for _, ai := range authorData {
+		sparklines[ai] = Sparkline(ai.commitTimes, from, to)
+	}
+	return sparklines
+}
+
+func AuthorLink(email string) string {
+	login, ok := GitHubLogin(email)
+	if ok {
+		return "https://github.com/" + login
+	}
+	if len(email) > 0 {
+		return "mailto:" + email
+	}
+	return ""
+}
+
+func Hyperlink(url, text string) string {
+	if len(url) == 0 {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+func WriteLinkedTabular(fi *FlagInfo, authorData AuthorData, showBars, showTrend bool) error {
+	totalLines := TotalLines(fi, authorData)
+	nameColumn := slices.Index(fi.columns, "name")
+	sparklines := Sparklines(authorData)
+
+	header := ColumnTitles(fi)
+	if showTrend {
+		header = append(header, "Trend")
+	}
+	if showBars {
+		header = append(header, "Share")
+	}
+	rows := [][]string{header}
+	links := []string{""}
+	for _, ai := range authorData {
+		columns := ai.Columns(fi)
+		if fi.humanize {
+			HumanizeRow(fi, columns)
+		}
+		if showTrend {
+			columns = append(columns, sparklines[ai])
+		}
+		if showBars {
+			columns = append(columns, RenderBar(AuthorColor(fi, ai.Name), ai.Lines, totalLines, fi.barWidth))
+		}
+		rows = append(rows, columns)
+		links = append(links, AuthorLink(ai.Email))
+	}
+
+	// Escape sequences would be counted by tabwriter, so columns are aligned by visible width here.
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		for j, cell := range row {
+			if j == nameColumn {
+				b.WriteString(Hyperlink(links[i], cell))
+			} else {
+				b.WriteString(cell)
+			}
+
+			switch {
+			case j == len(row)-1:
+				b.WriteString("\n")
+			case fi.noAlign:
+				b.WriteString("\t")
+			default:
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)+1))
+			}
+		}
+	}
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+func WriteTabular(fi *FlagInfo, authorData AuthorData) error {
+	showBars := UseColor(fi)
+
+	stat, err := os.Stdout.Stat()
+	isTerminal := err == nil && stat.Mode()&os.ModeCharDevice != 0
+	showTrend := fi.sparkline && isTerminal
+	if fi.hyperlinks && isTerminal && CheckEntry("name", fi.columns) {
+		return WriteLinkedTabular(fi, authorData, showBars, showTrend)
+	}
+
+	var w io.Writer = os.Stdout
+	if !fi.noAlign {
+		tw := new(tabwriter.Writer)
+		tw.Init(os.Stdout, 0, 0, 1, ' ', 0)
+		defer tw.Flush()
+		w = tw
+	}
+	totalLines := TotalLines(fi, authorData)
+	var sparklines map[*AuthorInfo]string
+	if showTrend {
+		sparklines = Sparklines(authorData)
+	}
+
+	header := strings.Join(ColumnTitles(fi), "\t")
+	if showTrend {
+		header += "\tTrend"
+	}
+	if showBars {
+		header += "\tShare"
+	}
+	_, err = fmt.Fprintln(w, header)
+	if err != nil {
+		return err
+	}
+
+	for _, ai := range authorData {
+		columns := ai.Columns(fi)
+		if fi.humanize {
+			HumanizeRow(fi, columns)
+		}
+		row := strings.Join(columns, "\t")
+		if showTrend {
+			row += "\t" + sparklines[ai]
+		}
+		if showBars {
+			row += "\t" + RenderBar(AuthorColor(fi, ai.Name), ai.Lines, totalLines, fi.barWidth)
+		}
+		_, err = fmt.Fprintln(w, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteCSV(fi *FlagInfo, out io.Writer, authorData AuthorData) error {
+	if fi.csvBOM {
+		_, err := io.WriteString(out, "\uFEFF")
+		if err != nil {
+			return err
+		}
+	}
+
+	w := csv.NewWriter(out)
+	w.Comma = fi.csvComma
+	w.UseCRLF = fi.csvCRLF
+	defer w.Flush()
+
+	err := w.Write(ColumnTitles(fi))
+	if err != nil {
+		return err
+	}
+
+	for _, ci := range authorData {
+		err = w.Write(ci.Columns(fi))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type JSONWrap struct {
+	GeneratedAt string     `json:"generated_at"`
+	Revision    string     `json:"revision"`
+	Partial     bool       `json:"partial,omitempty"`
+	ToolVersion string     `json:"tool_version"`
+	GitVersion  string     `json:"git_version"`
+	Authors     AuthorData `json:"authors"`
+}
+
+func MarshalJSON(fi *FlagInfo, data any) ([]byte, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil || !fi.jsonSortKeys {
+		return jsonData, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+
+	var generic any
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+func WriteJSON(fi *FlagInfo, out io.Writer, authorData AuthorData) error {
+	var data any = authorData
+	if fi.jsonWrap {
+		gitVersion, err := GitVersion()
+		if err != nil {
+			return err
+		}
+
+		data = &JSONWrap{
+			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+			Revision:    fi.revisionHash,
+			Partial:     fi.timedOut,
+			ToolVersion: version,
+			GitVersion:  gitVersion,
+			Authors:     authorData,
+		}
+	}
+
+	jsonData, err := MarshalJSON(fi, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(jsonData)
+	return err
+}
+
+func WriteJSONLines(fi *FlagInfo, authorData AuthorData) error {
+	for _, ci := range authorData {
+		jsonData, err := MarshalJSON(fi, ci)
+		if err != nil {
+			return err
+		}
+
+		os.Stdout.Write(jsonData)
+		os.Stdout.Write([]byte("\n"))
+	}
+
+	return nil
+}
+
+func EscapeXML(str string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(str))
+	return b.String()
+}
+
+func WriteSVGBadge(fi *FlagInfo, authorData AuthorData) error {
+	const charWidth, padding = 7, 10
+	const format = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[5]s: %[6]s">` +
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` +
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>` +
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[8]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>` +
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` +
+		`<text x="%[4]d" y="14">%[5]s</text><text x="%[7]d" y="14">%[6]s</text></g></svg>` + "\n"
+
+	value, color := "none", "#9f9f9f"
+	if len(authorData) > 0 {
+		rgb := AuthorColor(fi, authorData[0].Name)
+		color = fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+
+		share := 0.0
+		if totalLines := TotalLines(fi, authorData); totalLines > 0 {
+			share = float64(authorData[0].Lines) * 100 / float64(totalLines)
+		}
+		value = fmt.Sprintf("%s %.0f%%", authorData[0].Name, share)
+	}
+
+	labelWidth := len([]rune(fi.badgeLabel))*charWidth + padding
+	valueWidth := len([]rune(value))*charWidth + padding
+
+	_, err := fmt.Fprintf(os.Stdout, format, labelWidth+valueWidth, labelWidth, valueWidth,
+		labelWidth/2, EscapeXML(fi.badgeLabel), EscapeXML(value), labelWidth+valueWidth/2, color)
+	return err
+}
+
+type CollaborationInfo struct {
+	File        string   `json:"file"`
+	AuthorCount int      `json:"author_count"`
+	Authors     []string `json:"authors"`
+}
+
+func WriteCollaboration(fileData FileData) error {
+	collaborationData := []*CollaborationInfo{}
+	for name, info := range fileData {
+		authors := info.authors
+		if len(authors) == 0 {
+			continue
+		}
+
+		ci := &CollaborationInfo{File: name, AuthorCount: len(authors), Authors: []string{}}
+		for author := range authors {
+			ci.Authors = append(ci.Authors, author)
+		}
+		sort.Strings(ci.Authors)
+		collaborationData = append(collaborationData, ci)
+	}
+
+	sort.Slice(collaborationData, func(i, j int) bool {
+		if collaborationData[i].AuthorCount != collaborationData[j].AuthorCount {
+			return collaborationData[i].AuthorCount > collaborationData[j].AuthorCount
+		}
+		return collaborationData[i].File < collaborationData[j].File
+	})
+
+	jsonData, err := json.Marshal(collaborationData)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(jsonData)
+
+	return nil
+}
+
+func WriteByExtension(fi *FlagInfo, fileData FileData) error {
+	extensionData := make(map[string]map[string]int)
+	for name, info := range fileData {
+		group := path.Ext(name)
+		language, ok := fi.extLanguages[group]
+		if ok {
+			group = language
+		}
+
+		for author, lines := range info.authors {
+			_, ok := extensionData[author]
+			if !ok {
+				extensionData[author] = make(map[string]int)
+			}
+			extensionData[author][group] += lines
+		}
+	}
+
+	jsonData, err := json.Marshal(extensionData)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(jsonData)
+
+	return nil
+}
+
+type RollupAuthor struct {
+	Lines int `json:"lines"`
+	Files int `json:"files"`
+}
+
+type RollupNode struct {
+	Lines    int                      `json:"lines"`
+	Files    int                      `json:"files"`
+	Authors  map[string]*RollupAuthor `json:"authors"`
+	Children map[string]*RollupNode   `json:"children,omitempty"`
+}
+
+func (rn *RollupNode) Add(info *FileInfo) {
+	rn.Lines += info.lines
+	rn.Files++
+	for author, lines := range info.authors {
+		_, ok := rn.Authors[author]
+		if !ok {
+			rn.Authors[author] = &RollupAuthor{}
+		}
+		rn.Authors[author].Lines += lines
+		rn.Authors[author].Files++
+	}
+}
+
+func WriteRollup(fi *FlagInfo, fileData FileData) error {
+	root := &RollupNode{Authors: make(map[string]*RollupAuthor)}
+	for name, info := range fileData {
+		root.Add(info)
+
+		node := root
+		dirs := strings.Split(name, "/")
+		dirs = dirs[:len(dirs)-1]
+		for _, dir := range dirs[:min(len(dirs), fi.rollupDepth)] {
+			if node.Children == nil {
+				node.Children = make(map[string]*RollupNode)
+			}
+			child, ok := node.Children[dir]
+			if !ok {
+				child = &RollupNode{Authors: make(map[string]*RollupAuthor)}
+				node.Children[dir] = child
+			}
+			child.Add(info)
+			node = child
+		}
+	}
+
+	jsonData, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(jsonData)
+
+	return nil
+}
+
+type StaleInfo struct {
+	File         string `json:"file"`
+	LastModified string `json:"last_modified"`
+	AgeDays      int    `json:"age_days"`
+	Author       string `json:"author"`
+}
+
+func WriteStaleFiles(fi *FlagInfo, fileData FileData) error {
+	now := time.Now()
+
+	staleData := []*StaleInfo{}
+	for name, info := range fileData {
+		modified := time.Unix(info.modified, 0)
+		age := now.Sub(modified)
+		if len(info.authors) == 0 || age < fi.staleAge {
+			continue
+		}
+
+		author := ""
+		for a, lines := range info.authors {
+			if len(author) == 0 || lines > info.authors[author] || (lines == info.authors[author] && a < author) {
+				author = a
+			}
+		}
+
+		staleData = append(staleData, &StaleInfo{
+			File:         name,
+			LastModified: modified.UTC().Format(time.RFC3339),
+			AgeDays:      int(age.Hours() / 24),
+			Author:       author,
+		})
+	}
+
+	sort.Slice(staleData, func(i, j int) bool {
+		if staleData[i].LastModified != staleData[j].LastModified {
+			return staleData[i].LastModified < staleData[j].LastModified
+		}
+		return staleData[i].File < staleData[j].File
+	})
+
+	jsonData, err := json.Marshal(staleData)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(jsonData)
+
+	return nil
+}
+
+func WritePlist(authorData AuthorData) error {
+	const header = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<array>
+`
+	const format = `	<dict>
+		<key>name</key>
+		<string>%s</string>
+		<key>lines</key>
+		<integer>%d</integer>
+		<key>commits</key>
+		<integer>%d</integer>
+		<key>files</key>
+		<integer>%d</integer>
+	</dict>
+`
+
+	_, err := os.Stdout.WriteString(header)
+	if err != nil {
+		return err
+	}
+
+	for _, ai := range authorData {
+		_, err = fmt.Fprintf(os.Stdout, format, EscapeXML(ai.Name), ai.Lines, ai.Commits, ai.Files)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = os.Stdout.WriteString("</array>\n</plist>\n")
+	return err
+}
+
+func RevisionTime(fi *FlagInfo) (int64, error) {
+	res, err := GitOutput(context.Background(), fi, "show", "-s", "--format=%ct", fi.revisionHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(res)), 10, 64)
+}
+
+type Sample struct {
+	hash string
+	date string
+}
+
+var sampleLayouts = map[string]string{
+	"daily":   "2006-01-02",
+	"monthly": "2006-01",
+	"yearly":  "2006",
+}
+
+func SampleKey(interval string, t time.Time) string {
+	if interval == "weekly" {
+		year, week := t.ISOWeek()
+		return strconv.Itoa(year) + "-" + strconv.Itoa(week)
+	}
+	return t.Format(sampleLayouts[interval])
+}
+
+func SampleRevisions(fi *FlagInfo) ([]Sample, error) {
+	interval := fi.timeseries[0]
+	_, ok := sampleLayouts[interval]
+	if len(fi.timeseries) == 1 && (ok || interval == "weekly") {
+		res, err := GitOutput(context.Background(), fi, "log", "--first-parent", "--format=%H %ct", "--end-of-options", fi.revisionHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var samples []Sample
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(strings.TrimSpace(string(res)), "\n") {
+			hash, timestamp, _ := strings.Cut(line, " ")
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			t := time.Unix(seconds, 0).UTC()
+			key := SampleKey(interval, t)
+			if !seen[key] {
+				seen[key] = true
+				samples = append(samples, Sample{hash: hash, date: t.Format("2006-01-02")})
+			}
+		}
+
+		slices.Reverse(samples)
+		return samples, nil
+	}
+
+	var samples []Sample
+	for _, revision := range fi.timeseries {
+		hash, err := ResolveCommit(fi, revision)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := GitOutput(context.Background(), fi, "show", "-s", "--format=%ct", hash)
+		if err != nil {
+			return nil, err
+		}
+
+		seconds, err := strconv.ParseInt(strings.TrimSpace(string(res)), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{hash: hash, date: time.Unix(seconds, 0).UTC().Format("2006-01-02")})
+	}
+	return samples, nil
+}
+
+func WriteTimeseries(fi *FlagInfo, ei *ExtensionInfo) error {
+	samples, err := SampleRevisions(fi)
+	if err != nil {
+		return err
+	}
+
+	if fi.csvBOM {
+		_, err := io.WriteString(os.Stdout, "\uFEFF")
+		if err != nil {
+			return err
+		}
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = fi.csvComma
+	w.UseCRLF = fi.csvCRLF
+	defer w.Flush()
+
+	err = w.Write([]string{"date", "revision", "author", "lines"})
+	if err != nil {
+		return err
+	}
+
+	for i, sample := range samples {
+		ReportMessage(fi, slog.LevelInfo, "analyzing sample", "revision", sample.hash, "date", sample.date, "done", i, "total", len(samples))
+
+		fi.revisionHash, fi.treeHash = sample.hash, sample.hash
+		if fi.splitImport || fi.excludeRoot {
+			err = FindRootCommits(fi)
+			if err != nil {
+				return err
+			}
+		}
+
+		files, err := FindFiles(fi, ei)
+		if err != nil {
+			return err
+		}
+
+		authorData, _, err := CollectStatistics(fi, files)
+		if err != nil {
+			return err
+		}
+		SortData(fi, authorData)
+
+		for _, ai := range authorData {
+			err = w.Write([]string{sample.date, sample.hash, ai.Name, strconv.Itoa(ai.Lines)})
+			if err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		err = w.Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func EscapeInflux(str string, chars string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if strings.ContainsRune(chars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func WriteInflux(fi *FlagInfo, authorData AuthorData) error {
+	const format = "%s,author=%s,repo=%s lines=%di,commits=%di,files=%di %d\n"
+
+	timestamp, err := RevisionTime(fi)
+	if err != nil {
+		return err
+	}
+
+	repository, err := filepath.Abs(fi.repository)
+	if err != nil {
+		return err
+	}
+
+	measurement := EscapeInflux(fi.measurement, ", ")
+	repoName := filepath.Base(repository)
+	if len(fi.remote) > 0 {
+		repoName = strings.TrimSuffix(path.Base(fi.remote), ".git")
+	}
+	repo := EscapeInflux(repoName, ",= ")
+	for _, ai := range authorData {
+		_, err = fmt.Fprintf(os.Stdout, format, measurement, EscapeInflux(ai.Name, ",= "), repo,
+			ai.Lines, ai.Commits, ai.Files, timestamp*int64(time.Second))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteOrg(fi *FlagInfo, authorData AuthorData) error {
+	rows := [][]string{ColumnTitles(fi)}
+	for _, ai := range authorData {
+		row := ai.Columns(fi)
+		for i := range row {
+			row[i] = strings.ReplaceAll(row[i], "|", "\\vert{}")
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(fi.columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+
+	writeRow := func(row []string) error {
+		line := "|"
+		for i, cell := range row {
+			line += " " + cell + strings.Repeat(" ", widths[i]-len([]rune(cell))) + " |"
+		}
+		_, err := fmt.Fprintln(os.Stdout, line)
+		return err
+	}
+
+	err := writeRow(rows[0])
+	if err != nil {
+		return err
+	}
+
+	separator := "|"
+	for i, width := range widths {
+		if i > 0 {
+			separator += "+"
+		}
+		separator += strings.Repeat("-", width+2)
+	}
+	_, err = fmt.Fprintln(os.Stdout, separator+"|")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows[1:] {
+		err = writeRow(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type DotEdge struct {
+	author string
+	file   string
+	lines  int
+}
+
+func WriteAsciiDoc(fi *FlagInfo, authorData AuthorData) error {
+	var b strings.Builder
+	b.WriteString("[options=\"header\"]\n|===\n")
+
+	writeRow := func(row []string) {
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString("| " + strings.ReplaceAll(cell, "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(ColumnTitles(fi))
+	for _, ai := range authorData {
+		writeRow(ai.Columns(fi))
+	}
+	b.WriteString("|===\n")
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+func EscapeConfluence(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if strings.ContainsRune("\\|*_-+^~?{}[]!#", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return " "
+	}
+	return b.String()
+}
+
+func WriteConfluence(fi *FlagInfo, authorData AuthorData) error {
+	var b strings.Builder
+
+	b.WriteString("||")
+	for _, title := range ColumnTitles(fi) {
+		b.WriteString(EscapeConfluence(title) + "||")
+	}
+	b.WriteString("\n")
+
+	for _, ai := range authorData {
+		b.WriteString("|")
+		for _, cell := range ai.Columns(fi) {
+			b.WriteString(EscapeConfluence(cell) + "|")
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+type ThriftStruct struct {
+	b    []byte
+	last int
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func ThriftVarint(b []byte, v int64) []byte {
+	return binary.AppendUvarint(b, uint64(v<<1^v>>63))
+}
+
+func (ts *ThriftStruct) Field(id int, kind byte) {
+	delta := id - ts.last
+	if delta > 0 && delta <= 15 {
+		ts.b = append(ts.b, byte(delta<<4)|kind)
+	} else {
+		ts.b = ThriftVarint(append(ts.b, kind), int64(id))
+	}
+	ts.last = id
+}
+
+func (ts *ThriftStruct) Int(id int, kind byte, v int64) {
+	ts.Field(id, kind)
+	ts.b = ThriftVarint(ts.b, v)
+}
+
+func (ts *ThriftStruct) String(id int, v string) {
+	ts.Field(id, thriftBinary)
+	ts.b = append(binary.AppendUvarint(ts.b, uint64(len(v))), v...)
+}
+
+func (ts *ThriftStruct) Struct(id int, v *ThriftStruct) {
+	ts.Field(id, thriftStruct)
+	ts.b = append(ts.b, v.Bytes()...)
+}
+
+func (ts *ThriftStruct) List(id int, kind byte, elems [][]byte) {
+	ts.Field(id, thriftList)
+	if len(elems) < 15 {
+		ts.b = append(ts.b, byte(len(elems)<<4)|kind)
+	} else {
+		ts.b = binary.AppendUvarint(append(ts.b, 0xf0|kind), uint64(len(elems)))
+	}
+	for _, elem := range elems {
+		ts.b = append(ts.b, elem...)
+	}
+}
+
+func (ts *ThriftStruct) Bytes() []byte {
+	return append(slices.Clip(ts.b), 0)
+}
+
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetUTF8      = 0
+)
+
+func ColumnType(column string) string {
+	if CheckEntry(column, []string{"repo", "name", "email"}) {
+		return "TEXT"
+	}
+	if column == "score" {
+		return "REAL"
+	}
+	return "INTEGER"
+}
+
+func (ai *AuthorInfo) Value(column string) (any, error) {
+	cell := ai.Column(column)
+	switch ColumnType(column) {
+	case "TEXT":
+		return cell, nil
+	case "REAL":
+		return strconv.ParseFloat(cell, 64)
+	}
+	return strconv.ParseInt(cell, 10, 64)
+}
+
+func ParquetType(column string) int64 {
+	switch ColumnType(column) {
+	case "TEXT":
+		return parquetByteArray
+	case "REAL":
+		return parquetDouble
+	}
+	return parquetInt64
+}
+
+func ParquetValues(column string, authorData AuthorData) ([]byte, error) {
+	var b []byte
+	for _, ai := range authorData {
+		value, err := ai.Value(column)
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case string:
+			b = append(binary.LittleEndian.AppendUint32(b, uint32(len(v))), v...)
+		case float64:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		case int64:
+			b = binary.LittleEndian.AppendUint64(b, uint64(v))
+		}
+	}
+	return b, nil
+}
+
+func WriteParquet(fi *FlagInfo, w io.Writer, authorData AuthorData) error {
+	rows := int64(len(authorData))
+	root := &ThriftStruct{}
+	root.String(4, "schema")
+	root.Int(5, thriftI32, int64(len(fi.columns)))
+	schema := [][]byte{root.Bytes()}
+
+	file := []byte("PAR1")
+	var chunks [][]byte
+	totalSize := int64(0)
+	for _, column := range fi.columns {
+		values, err := ParquetValues(column, authorData)
+		if err != nil {
+			return err
+		}
+
+		element := &ThriftStruct{}
+		element.Int(1, thriftI32, ParquetType(column))
+		element.Int(3, thriftI32, 0)
+		element.String(4, column)
+		if ParquetType(column) == parquetByteArray {
+			element.Int(6, thriftI32, parquetUTF8)
+		}
+		schema = append(schema, element.Bytes())
+
+		page := &ThriftStruct{}
+		page.Int(1, thriftI32, rows)
+		page.Int(2, thriftI32, 0)
+		page.Int(3, thriftI32, 3)
+		page.Int(4, thriftI32, 3)
+		header := &ThriftStruct{}
+		header.Int(1, thriftI32, 0)
+		header.Int(2, thriftI32, int64(len(values)))
+		header.Int(3, thriftI32, int64(len(values)))
+		header.Struct(5, page)
+
+		offset := int64(len(file))
+		file = append(append(file, header.Bytes()...), values...)
+		size := int64(len(file)) - offset
+		totalSize += size
+
+		meta := &ThriftStruct{}
+		meta.Int(1, thriftI32, ParquetType(column))
+		meta.List(2, thriftI32, [][]byte{ThriftVarint(nil, 0)})
+		meta.List(3, thriftBinary, [][]byte{append(binary.AppendUvarint(nil, uint64(len(column))), column...)})
+		meta.Int(4, thriftI32, 0)
+		meta.Int(5, thriftI64, rows)
+		meta.Int(6, thriftI64, size)
+		meta.Int(7, thriftI64, size)
+		meta.Int(9, thriftI64, offset)
+		chunk := &ThriftStruct{}
+		chunk.Int(2, thriftI64, offset)
+		chunk.Struct(3, meta)
+		chunks = append(chunks, chunk.Bytes())
+	}
+
+	rowGroup := &ThriftStruct{}
+	rowGroup.List(1, thriftStruct, chunks)
+	rowGroup.Int(2, thriftI64, totalSize)
+	rowGroup.Int(3, thriftI64, rows)
+
+	metadata := &ThriftStruct{}
+	metadata.Int(1, thriftI32, 1)
+	metadata.List(2, thriftStruct, schema)
+	metadata.Int(3, thriftI64, rows)
+	metadata.List(4, thriftStruct, [][]byte{rowGroup.Bytes()})
+	metadata.String(6, "gitfame "+version)
+	footer := metadata.Bytes()
+
+	file = append(file, footer...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(footer)))
+	file = append(file, "PAR1"...)
+	_, err := w.Write(file)
+	return err
+}
+
+const sqlitePageSize = 4096
+
+func SQLiteVarint(b []byte, v uint64) []byte {
+	var digits []byte
+	for {
+		digits = append(digits, byte(v&0x7f))
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := len(digits) - 1; i > 0; i-- {
+		b = append(b, digits[i]|0x80)
+	}
+	return append(b, digits[0])
+}
+
+func SQLiteRecord(values []any) []byte {
+	var types, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			types = SQLiteVarint(types, uint64(len(v))*2+13)
+			body = append(body, v...)
+		case float64:
+			types = SQLiteVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case int64:
+			types = SQLiteVarint(types, 6)
+			body = binary.BigEndian.AppendUint64(body, uint64(v))
+		}
+	}
+
+	headerSize := len(types) + 1
+	if headerSize > 0x7f {
+		headerSize++
+	}
+	return append(append(SQLiteVarint(nil, uint64(headerSize)), types...), body...)
+}
+
+func SQLiteCell(rowid int64, values []any) ([]byte, error) {
+	record := SQLiteRecord(values)
+	if len(record) > sqlitePageSize-35 {
+		return nil, errors.New("sqlite row too large: " + strconv.FormatInt(rowid, 10))
+	}
+	cell := SQLiteVarint(nil, uint64(len(record)))
+	cell = SQLiteVarint(cell, uint64(rowid))
+	return append(cell, record...), nil
+}
+
+func SQLitePage(kind byte, offset int, cells [][]byte, rightmost int) []byte {
+	page := make([]byte, sqlitePageSize)
+	page[offset] = kind
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	pointers := offset + 8
+	if kind == 0x05 {
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightmost))
+		pointers += 4
+	}
+
+	content := sqlitePageSize
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointers+2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+	return page
+}
+
+func WriteSQLite(fi *FlagInfo, w io.Writer, authorData AuthorData) error {
+	columns := []string{"name", "email", "lines", "commits", "files"}
+	for _, column := range fi.columns {
+		if !CheckEntry(column, columns) {
+			columns = append(columns, column)
+		}
+	}
+
+	var definitions []string
+	for _, column := range columns {
+		definitions = append(definitions, column+" "+ColumnType(column))
+	}
+	schema := "CREATE TABLE authors(" + strings.Join(definitions, ", ") + ")"
+
+	type child struct {
+		page  int
+		rowid int64
+	}
+	pages := [][]byte{nil}
+	var children []child
+	var cells [][]byte
+	used := 8
+	flush := func(rowid int64) {
+		pages = append(pages, SQLitePage(0x0d, 0, cells, 0))
+		children = append(children, child{len(pages), rowid})
+		cells, used = nil, 8
+	}
+	for i, ai := range authorData {
+		var values []any
+		for _, column := range columns {
+			value, err := ai.Value(column)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		rowid := int64(i + 1)
+		cell, err := SQLiteCell(rowid, values)
+		if err != nil {
+			return err
+		}
+
+		if used+len(cell)+2 > sqlitePageSize {
+			flush(rowid - 1)
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+	flush(int64(len(authorData)))
+
+	for len(children) > 1 {
+		var parents []child
+		for len(children) > 0 {
+			used = 12
+			n := 0
+			for n < len(children)-1 {
+				size := 4 + len(SQLiteVarint(nil, uint64(children[n].rowid))) + 2
+				if used+size > sqlitePageSize {
+					if len(children)-n == 2 {
+						n--
+					}
+					break
+				}
+				used += size
+				n++
+			}
+
+			var pointers [][]byte
+			for _, c := range children[:n] {
+				pointers = append(pointers, SQLiteVarint(binary.BigEndian.AppendUint32(nil, uint32(c.page)), uint64(c.rowid)))
+			}
+			pages = append(pages, SQLitePage(0x05, 0, pointers, children[n].page))
+			parents = append(parents, child{len(pages), children[n].rowid})
+			children = children[n+1:]
+		}
+		children = parents
+	}
+
+	master, err := SQLiteCell(1, []any{"table", "authors", "authors", int64(children[0].page), schema})
+	if err != nil {
+		return err
+	}
+	pages[0] = SQLitePage(0x0d, 100, [][]byte{master}, 0)
+
+	header := pages[0]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], sqlitePageSize)
+	header[18], header[19] = 1, 1
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(pages)))
+	binary.BigEndian.PutUint32(header[40:], 1)
+	binary.BigEndian.PutUint32(header[44:], 4)
+	binary.BigEndian.PutUint32(header[56:], 1)
+	binary.BigEndian.PutUint32(header[92:], 1)
+	binary.BigEndian.PutUint32(header[96:], 3040001)
+
+	for _, page := range pages {
+		_, err = w.Write(page)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteDot(fi *FlagInfo, fileData FileData) error {
+	edges := []*DotEdge{}
+	authors := make(map[string]bool)
+	files := make(map[string]bool)
+	for name, info := range fileData {
+		for author, lines := range info.authors {
+			if lines < fi.minEdgeLines {
+				continue
+			}
+			edges = append(edges, &DotEdge{author: author, file: name, lines: lines})
+			authors[author] = true
+			files[name] = true
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].file != edges[j].file {
+			return edges[i].file < edges[j].file
+		}
+		return edges[i].author < edges[j].author
+	})
+
+	authorNames := []string{}
+	for author := range authors {
+		authorNames = append(authorNames, author)
+	}
+	sort.Strings(authorNames)
+
+	fileNames := []string{}
+	for name := range files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	escape := func(str string) string {
+		return EscapeInflux(str, `"\`)
+	}
+
+	var b strings.Builder
+	b.WriteString("graph gitfame {\n\trankdir=LR;\n")
+	for _, author := range authorNames {
+		color := AuthorColor(fi, author)
+		fmt.Fprintf(&b, "\t\"a:%[1]s\" [label=\"%[1]s\", shape=ellipse, style=filled, fillcolor=\"#%02[2]x%02[3]x%02[4]x\"];\n",
+			escape(author), color[0], color[1], color[2])
+	}
+	for _, name := range fileNames {
+		fmt.Fprintf(&b, "\t\"f:%[1]s\" [label=\"%[1]s\", shape=box];\n", escape(name))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "\t\"a:%s\" -- \"f:%s\" [weight=%d, label=\"%d\"];\n",
+			escape(edge.author), escape(edge.file), edge.lines, edge.lines)
+	}
+	b.WriteString("}\n")
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+func QuoteShell(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+func WriteKV(authorData AuthorData) error {
+	var b strings.Builder
+	if len(authorData) > 0 {
+		top := authorData[0]
+		fmt.Fprintf(&b, "TOP_AUTHOR=%s\nTOP_LINES=%d\nTOP_COMMITS=%d\nTOP_FILES=%d\n",
+			QuoteShell(top.Name), top.Lines, top.Commits, top.Files)
+	}
+	fmt.Fprintf(&b, "AUTHOR_COUNT=%d\n", len(authorData))
+	for i, ai := range authorData {
+		fmt.Fprintf(&b, "AUTHOR_%[1]d=%[2]s\nLINES_%[1]d=%[3]d\nCOMMITS_%[1]d=%[4]d\nFILES_%[1]d=%[5]d\n",
+			i+1, QuoteShell(ai.Name), ai.Lines, ai.Commits, ai.Files)
+	}
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
+
+func WriteData(fi *FlagInfo, authorData AuthorData, fileData FileData) error {
+	var err error
+	if fi.format == "tabular" {
+		err = WriteTabular(fi, authorData)
+	} else if fi.format == "csv" {
+		err = WriteCSV(fi, os.Stdout, authorData)
+	} else if fi.format == "json" {
+		err = WriteJSON(fi, os.Stdout, authorData)
+	} else if fi.format == "json-lines" {
+		err = WriteJSONLines(fi, authorData)
+	} else if fi.format == "svg-badge" {
+		err = WriteSVGBadge(fi, authorData)
+	} else if fi.format == "plist" {
+		err = WritePlist(authorData)
+	} else if fi.format == "influx" {
+		err = WriteInflux(fi, authorData)
+	} else if fi.format == "org" {
+		err = WriteOrg(fi, authorData)
+	} else if fi.format == "dot" {
+		err = WriteDot(fi, fileData)
+	} else if fi.format == "kv" {
+		err = WriteKV(authorData)
+	} else if fi.format == "asciidoc" {
+		err = WriteAsciiDoc(fi, authorData)
+	} else if fi.format == "chart" {
+		err = WriteChart(fi, authorData)
+	} else if fi.format == "confluence" {
+		err = WriteConfluence(fi, authorData)
+	} else if fi.format == "parquet" {
+		err = WriteFile(fi.output, func(w io.Writer) error {
+			return WriteParquet(fi, w, authorData)
+		})
+	} else if fi.format == "sqlite" {
+		err = WriteFile(fi.output, func(w io.Writer) error {
+			return WriteSQLite(fi, w, authorData)
+		})
+	}
+	return err
+}
+
+func BusFactor(authorData AuthorData) int {
+	lines := []int{}
+	totalLines := 0
+	for _, ai := range authorData {
+		lines = append(lines, ai.Lines)
+		totalLines += ai.Lines
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lines)))
+
+	owned := 0
+	for i, l := range lines {
+		owned += l
+		if owned*2 > totalLines {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+func Metrics(fi *FlagInfo, authorData AuthorData) map[string]float64 {
+	topLines := 0
+	for _, ai := range authorData {
+		topLines = max(topLines, ai.Lines)
+	}
+
+	topPercent := 0.0
+	totalLines := TotalLines(fi, authorData)
+	if totalLines > 0 {
+		topPercent = float64(topLines) * 100 / float64(totalLines)
+	}
+
+	return map[string]float64{
+		"top_author_lines_pct": topPercent,
+		"author_count":         float64(len(authorData)),
+		"bus_factor":           float64(BusFactor(authorData)),
+	}
+}
+
+func CheckAssertions(fi *FlagInfo, authorData AuthorData) bool {
+	metrics := Metrics(fi, authorData)
+
+	passed := true
+	for _, a := range fi.assertions {
+		actual := metrics[a.metric]
+		if a.Holds(actual) {
+			ReportMessage(fi, slog.LevelError, "assertion failed", "assertion", a.input, "metric", a.metric, "value", actual)
+			passed = false
+		}
+	}
+	return passed
+}
+
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+func CopyToClipboard(content []byte) error {
+	for _, args := range clipboardCommands {
+		_, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(content)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard tool found")
+}
+
+func CaptureStdout(write func() error) ([]byte, error) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	var captured bytes.Buffer
+	copied := make(chan error)
+	go func() {
+		_, err := io.Copy(io.MultiWriter(stdout, &captured), r)
+		r.Close()
+		copied <- err
+	}()
+
+	err = write()
+	w.Close()
+	copyErr := <-copied
+	if err != nil {
+		return nil, err
+	}
+	return captured.Bytes(), copyErr
+}
+
+func WriteFile(name string, write func(io.Writer) error) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func WriteAlso(fi *FlagInfo, authorData AuthorData) error {
+	if len(fi.alsoJSON) > 0 {
+		err := WriteFile(fi.alsoJSON, func(w io.Writer) error {
+			return WriteJSON(fi, w, authorData)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(fi.alsoCSV) > 0 {
+		return WriteFile(fi.alsoCSV, func(w io.Writer) error {
+			return WriteCSV(fi, w, authorData)
+		})
+	}
+	return nil
+}
+
+func StartProfile(fi *FlagInfo) (func() error, error) {
+	var cpuFile *os.File
+	if len(fi.cpuProfile) > 0 {
+		var err error
+		cpuFile, err = os.Create(fi.cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		err = pprof.StartCPUProfile(cpuFile)
+		if err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			err := cpuFile.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(fi.memProfile) > 0 {
+			memFile, err := os.Create(fi.memProfile)
+			if err != nil {
+				return err
+			}
+			defer memFile.Close()
+
+			return pprof.WriteHeapProfile(memFile)
+		}
+
+		return nil
+	}, nil
+}
+
+func AnalyzeRepository(fi *FlagInfo, ei *ExtensionInfo) (AuthorData, FileData, error) {
+	if !fi.noIgnoreFile && !IsRemote(fi.repository) {
+		err := ReadIgnoreFile(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if IsRemote(fi.repository) {
+		cleanup, err := CloneRepository(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer cleanup()
+	}
+
+	err := ResolveRevision(fi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fi.excludeSelf {
+		err = ResolveSelf(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if fi.sinceLastTag {
+		fi.diffBase, err = FindLastTag(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(fi.diffBase) > 0 {
+		err = FindDiffHunks(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	files, err := FindFiles(fi, ei)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fi.percentBase == "repo" {
+		err = CountRepoLines(fi)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return CollectStatistics(fi, files)
+}
+
+func CollectRepositories(fi *FlagInfo, ei *ExtensionInfo) (AuthorData, FileData, error) {
+	merged := make(map[string]*AuthorInfo)
+	fileData := make(FileData)
+
+	var firstErr error
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(len(fi.repositories))
+	semaphore := make(chan struct{}, fi.jobs)
+
+	for _, repository := range fi.repositories {
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			label, err := RepoName(repository)
+			if err != nil {
+				mu.Lock()
+				firstErr = cmp.Or(firstErr, err)
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			repoFi := *fi
+			mu.Unlock()
+			repoFi.repository = repository
+			repoFi.repoName = label
+			repoFi.exclude = slices.Clone(fi.exclude)
+
+			ReportMessage(fi, slog.LevelInfo, "analyzing repository", "repository", label)
+			start := time.Now()
+
+			authorData, repoFileData, err := AnalyzeRepository(&repoFi, ei)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				firstErr = cmp.Or(firstErr, fmt.Errorf("%s: %w", label, err))
+				return
+			}
+			ReportMessage(fi, slog.LevelInfo, "repository analyzed", "repository", label, "duration", time.Since(start).Round(time.Millisecond))
+
+			fi.repoLines += repoFi.repoLines
+			fi.timedOut = fi.timedOut || repoFi.timedOut
+			for name, info := range repoFileData {
+				fileData[label+":"+name] = info
+			}
+
+			for _, ai := range authorData {
+				key := ai.Name
+				if fi.showRepo {
+					key = label + "\x00" + ai.Name
+				}
+
+				total, ok := merged[key]
+				if !ok {
+					total = &AuthorInfo{Repo: ai.Repo, Name: ai.Name, Email: ai.Email, fileSet: make(map[string]bool), commitSet: make(map[string]bool), mergeSet: make(map[string]bool)}
+					if fi.classify {
+						total.LineClasses = &LineClasses{}
+					}
+					if fi.metric == "bytes" {
+						total.Bytes = new(int)
+					}
+					merged[key] = total
+				}
+
+				if ai.Lines > total.Lines {
+					total.Email = ai.Email
+				}
+				total.Lines += ai.Lines
+				for name := range ai.fileSet {
+					total.fileSet[label+":/"+name] = true
+				}
+				for commit := range ai.commitSet {
+					total.commitSet[label+":"+commit] = true
+				}
+				for commit := range ai.mergeSet {
+					total.mergeSet[label+":"+commit] = true
+				}
+				total.commitTimes = append(total.commitTimes, ai.commitTimes...)
+				if fi.classify {
+					total.LineClasses.Add(ai.LineClasses)
+				}
+				if fi.metric == "bytes" {
+					*total.Bytes += *ai.Bytes
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	var authorData AuthorData
+	for _, ai := range merged {
+		ai.Files = len(ai.fileSet)
+		ai.Commits = len(ai.commitSet)
+		if fi.showDirs {
+			dirs := make(map[string]bool)
+			for name := range ai.fileSet {
+				dirs[path.Dir(name)] = true
+			}
+			dirCount := len(dirs)
+			ai.Directories = &dirCount
+		}
+		if fi.showMerges {
+			merges := len(ai.mergeSet)
+			ai.Merges = &merges
+		}
+		authorData = append(authorData, ai)
+	}
+
+	return authorData, fileData, nil
+}
+
+func ReadReport(name string) (AuthorData, error) {
+	content, err := os.ReadFile(name)
 	if err != nil {
 		return nil, err
 	}
 
-	CheckName := func(name string) (bool, error) {
-		if !ei.CheckName(fi, name) {
-			return false, nil
+	var authorData AuthorData
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for decoder.More() {
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
 		}
 
-		for _, pattern := range fi.exclude {
-			matched, err := path.Match(pattern, name)
+		if bytes.HasPrefix(value, []byte("[")) {
+			var authors AuthorData
+			err = json.Unmarshal(value, &authors)
 			if err != nil {
-				return false, err
-			}
-			if matched {
-				return false, nil
+				return nil, fmt.Errorf("%s: %w", name, err)
 			}
+			authorData = append(authorData, authors...)
+			continue
 		}
 
-		isFound := (len(fi.restrictTo) == 0)
-		for _, pattern := range fi.restrictTo {
-			matched, err := path.Match(pattern, name)
-			if err != nil {
-				return false, nil
-			}
-			if matched {
-				isFound = true
-				break
-			}
+		var wrap JSONWrap
+		err = json.Unmarshal(value, &wrap)
+		if err == nil && wrap.Authors != nil {
+			authorData = append(authorData, wrap.Authors...)
+			continue
 		}
 
-		return isFound, nil
-	}
-
-	var files []string
-	names := strings.Split(string(res), "\n")
-	names = names[:len(names)-1]
-	for _, name := range names {
-		isAllowed, err := CheckName(name)
+		ai := &AuthorInfo{}
+		err = json.Unmarshal(value, ai)
 		if err != nil {
-			return nil, err
-		}
-		if isAllowed {
-			files = append(files, name)
+			return nil, fmt.Errorf("%s: %w", name, err)
 		}
+		authorData = append(authorData, ai)
 	}
-
-	return files, nil
-}
-
-const commitLen = 40
-
-type CommitInfo struct {
-	commit    string
-	author    string
-	lineCount int
+	return authorData, nil
 }
 
-func AnalyzeEmptyFile(fi *FlagInfo, name string) (*CommitInfo, error) {
-	cmd := exec.Command("git", "log", fi.revision, "-n", "1", "--format=raw", "--", name)
-	cmd.Dir = fi.repository
-	res, err := cmd.Output()
-	if err != nil {
-		return nil, err
+func DiffReports(oldData, newData AuthorData) AuthorData {
+	deltas := make(map[string]*AuthorInfo)
+	delta := func(ai *AuthorInfo, sign int) {
+		d, ok := deltas[ai.Name]
+		if !ok {
+			d = &AuthorInfo{Name: ai.Name}
+			deltas[ai.Name] = d
+		}
+		d.Lines += sign * ai.Lines
+		d.Commits += sign * ai.Commits
+		d.Files += sign * ai.Files
 	}
-
-	lines := strings.Split(string(res), "\n")
-	i := 2
-	if !strings.HasPrefix(lines[i], "author") {
-		i++
+	for _, ai := range oldData {
+		delta(ai, -1)
 	}
-	author := strings.Split(lines[i], " <")[0][len("author "):]
-	if fi.useCommitter {
-		author = strings.Split(lines[i+1], " <")[0][len("committer "):]
+	for _, ai := range newData {
+		delta(ai, 1)
 	}
 
-	return &CommitInfo{
-		commit:    lines[0][len("commit "):],
-		author:    author,
-		lineCount: 0,
-	}, nil
-}
-
-func AnalyzeFile(fi *FlagInfo, name string) (map[string]*CommitInfo, error) {
-	cmd := exec.Command("git", "blame", name, "--porcelain", fi.revision)
-	cmd.Dir = fi.repository
-	res, err := cmd.Output()
-	if err != nil {
-		return nil, err
+	var authorData AuthorData
+	for _, d := range deltas {
+		if d.Lines != 0 || d.Commits != 0 || d.Files != 0 {
+			authorData = append(authorData, d)
+		}
 	}
+	return authorData
+}
 
-	commits := make(map[string]*CommitInfo)
+func WriteReports(fi *FlagInfo, authorData AuthorData, fileData FileData) {
+	var err error
 
-	lines := strings.Split(string(res), "\n")
-	lines = lines[:len(lines)-1]
+	if fi.collabReport {
+		ReportPhase(fi, "writing collaboration report")
 
-	if len(lines) == 0 {
-		ci, err := AnalyzeEmptyFile(fi, name)
+		err = WriteCollaboration(fileData)
 		if err != nil {
-			return nil, err
+			panic(err)
 		}
 
-		commits[ci.commit] = ci
-		return commits, nil
+		ReportPhase(fi, "done")
+		return
 	}
 
-	for i := 0; i < len(lines); {
-		commit := lines[i][:commitLen]
+	if fi.staleFiles {
+		ReportPhase(fi, "writing stale files report")
 
-		ci, ok := commits[commit]
-		if ok {
-			ci.lineCount++
-			i += 2
-		} else {
-			commits[commit] = &CommitInfo{
-				commit:    commit,
-				author:    lines[i+1][len("author "):],
-				lineCount: 1,
-			}
-			if fi.useCommitter {
-				commits[commit].author = lines[i+5][len("committer "):]
-			}
-			if !strings.HasPrefix(lines[i+10], "filename") {
-				i++
-			}
-			i += 12
+		err = WriteStaleFiles(fi, fileData)
+		if err != nil {
+			panic(err)
 		}
+
+		ReportPhase(fi, "done")
+		return
 	}
 
-	return commits, nil
-}
+	if fi.rollupDepth > 0 {
+		ReportPhase(fi, "writing directory rollup report")
 
-type AuthorInfo struct {
-	Name    string `json:"name"`
-	Commits int    `json:"commits"`
-	Lines   int    `json:"lines"`
-	Files   int    `json:"files"`
-}
+		err = WriteRollup(fi, fileData)
+		if err != nil {
+			panic(err)
+		}
 
-type AuthorData []*AuthorInfo
+		ReportPhase(fi, "done")
+		return
+	}
 
-func CollectStatistics(fi *FlagInfo, files []string) (AuthorData, error) {
-	fileCount := make(map[string]map[string]bool)
-	commitCount := make(map[string]map[string]bool)
-	lineCount := make(map[string]int)
+	if fi.byExtension {
+		ReportPhase(fi, "writing extension report")
 
-	mu := sync.Mutex{}
-	wg := sync.WaitGroup{}
-	wg.Add(len(files))
-	doneCount := 0
+		err = WriteByExtension(fi, fileData)
+		if err != nil {
+			panic(err)
+		}
 
-	for i := range files {
-		name := files[i]
+		ReportPhase(fi, "done")
+		return
+	}
 
-		go func() {
-			commits, err := AnalyzeFile(fi, name)
-			if err != nil {
-				panic(err)
-			}
+	if fi.minPercent > 0 {
+		authorData = FilterMinPercent(fi, authorData)
+	}
 
-			mu.Lock()
-			for _, ci := range commits {
-				_, ok := fileCount[ci.author]
-				if !ok {
-					fileCount[ci.author] = make(map[string]bool)
-				}
-				fileCount[ci.author][name] = true
+	if fi.weights != nil {
+		ComputeScores(fi, authorData)
+	}
 
-				_, ok = commitCount[ci.author]
-				if !ok {
-					commitCount[ci.author] = make(map[string]bool)
-				}
-				commitCount[ci.author][ci.commit] = true
+	ReportPhase(fi, "sorting data")
 
-				lineCount[ci.author] += ci.lineCount
-			}
+	SortData(fi, authorData)
 
-			doneCount++
-			os.Stderr.WriteString(fmt.Sprintf("analysis done by %d percent\n", doneCount*100/len(files)))
-			mu.Unlock()
+	allAuthors := authorData
+	if fi.top > 0 {
+		authorData = TruncateTop(fi, authorData)
+	}
 
-			wg.Done()
-		}()
+	if fi.ratios {
+		ComputeRatios(authorData)
 	}
 
-	wg.Wait()
+	ReportPhase(fi, "writing data")
 
-	var authorData AuthorData
-	for author := range fileCount {
-		authorData = append(authorData, &AuthorInfo{
-			Name:    author,
-			Commits: len(commitCount[author]),
-			Lines:   lineCount[author],
-			Files:   len(fileCount[author]),
+	if fi.clipboard {
+		output, err := CaptureStdout(func() error {
+			return WriteData(fi, authorData, fileData)
 		})
+		if err != nil {
+			panic(err)
+		}
+
+		err = CopyToClipboard(output)
+		if err != nil {
+			ReportMessage(fi, slog.LevelWarn, "copying to clipboard failed", "error", err)
+		}
+	} else {
+		err = WriteData(fi, authorData, fileData)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	return authorData, nil
-}
+	err = WriteAlso(fi, authorData)
+	if err != nil {
+		panic(err)
+	}
 
-func (ad AuthorData) Len() int {
-	return len(ad)
-}
+	if len(fi.assertions) > 0 {
+		ReportPhase(fi, "checking assertions")
 
-func (ad AuthorData) Swap(i, j int) {
-	ad[i], ad[j] = ad[j], ad[i]
-}
+		if !CheckAssertions(fi, allAuthors) {
+			os.Exit(1)
+		}
+	}
 
-var key = "lines"
+	ReportPhase(fi, "done")
+}
 
-func (ad AuthorData) Less(i, j int) bool {
-	iVal, jVal := ad[i].Lines, ad[j].Lines
-	if key == "commits" {
-		iVal, jVal = ad[i].Commits, ad[j].Commits
-	}
-	if key == "files" {
-		iVal, jVal = ad[i].Files, ad[j].Files
+func main() {
+	fi, err := ParseFlag()
+	if err != nil {
+		panic(err)
 	}
 
-	if iVal != jVal {
-		return iVal > jVal
-	}
-	if ad[i].Lines != ad[j].Lines {
-		return ad[i].Lines > ad[j].Lines
-	}
-	if ad[i].Commits != ad[j].Commits {
-		return ad[i].Commits > ad[j].Commits
-	}
-	if ad[i].Files != ad[j].Files {
-		return ad[i].Files > ad[j].Files
+	if fi.version {
+		gitVersion, err := GitVersion()
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("gitfame %s\ngit %s\n", version, gitVersion)
+		return
 	}
-	return ad[i].Name < ad[j].Name
-}
 
-func SortData(fi *FlagInfo, authorData AuthorData) {
-	key = fi.orderBy
-	sort.Sort(authorData)
-}
+	if len(fi.diffReports) > 0 {
+		oldData, err := ReadReport(fi.diffReports[0])
+		if err != nil {
+			panic(err)
+		}
+		newData, err := ReadReport(fi.diffReports[1])
+		if err != nil {
+			panic(err)
+		}
 
-func WriteTabular(authorData AuthorData) error {
-	w := new(tabwriter.Writer)
-	w.Init(os.Stdout, 0, 0, 1, ' ', 0)
-	defer w.Flush()
-	const format = "%v\t%v\t%v\t%v\n"
+		authorData := DiffReports(oldData, newData)
+		SortData(fi, authorData)
 
-	_, err := fmt.Fprintf(w, format, "Name", "Lines", "Commits", "Files")
+		err = WriteData(fi, authorData, nil)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	ReportPhase(fi, "starting")
+
+	stopProfile, err := StartProfile(fi)
 	if err != nil {
-		return err
+		panic(err)
 	}
+	defer func() {
+		err := stopProfile()
+		if err != nil {
+			panic(err)
+		}
+	}()
 
-	for _, ai := range authorData {
-		_, err = fmt.Fprintf(w, format, ai.Name, ai.Lines, ai.Commits, ai.Files)
+	if len(fi.repositories) > 0 {
+		ReportPhase(fi, "parsing extensions and languages")
+
+		ei, err := ParseExtension(fi)
 		if err != nil {
-			return err
+			panic(err)
+		}
+
+		ReportPhase(fi, "collecting statistics")
+
+		authorData, fileData, err := CollectRepositories(fi, ei)
+		if err != nil {
+			panic(err)
 		}
+
+		WriteReports(fi, authorData, fileData)
+		return
 	}
 
-	return nil
-}
+	if len(fi.archive) > 0 {
+		ReportPhase(fi, "extracting archive")
 
-func WriteCSV(authorData AuthorData) error {
-	w := csv.NewWriter(os.Stdout)
-	defer w.Flush()
+		cleanup, err := ExtractArchive(fi)
+		if err != nil {
+			panic(err)
+		}
+		defer cleanup()
+	}
 
-	err := w.Write([]string{"Name", "Lines", "Commits", "Files"})
-	if err != nil {
-		return err
+	if !fi.noIgnoreFile && !IsRemote(fi.repository) {
+		err = ReadIgnoreFile(fi)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	for _, ci := range authorData {
-		err = w.Write([]string{
-			ci.Name,
-			strconv.Itoa(ci.Lines),
-			strconv.Itoa(ci.Commits),
-			strconv.Itoa(ci.Files),
-		})
+	if IsRemote(fi.repository) {
+		ReportPhase(fi, "cloning repository")
+
+		cleanup, err := CloneRepository(fi)
 		if err != nil {
-			return err
+			panic(err)
 		}
+		defer cleanup()
 	}
 
-	return nil
-}
+	ReportPhase(fi, "resolving revision")
 
-func WriteJSON(authorData AuthorData) error {
-	jsonData, err := json.Marshal(authorData)
+	err = ResolveRevision(fi)
 	if err != nil {
-		return err
+		panic(err)
 	}
 
-	os.Stdout.Write(jsonData)
+	ReportMessage(fi, slog.LevelInfo, "analyzed revision", "revision", fi.revisionHash)
 
-	return nil
-}
+	if fi.excludeSelf {
+		err = ResolveSelf(fi)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-func WriteJSONLines(authorData AuthorData) error {
-	for _, ci := range authorData {
-		jsonData, err := json.Marshal(ci)
+	if fi.sinceLastTag {
+		ReportPhase(fi, "finding last tag")
+
+		fi.diffBase, err = FindLastTag(fi)
 		if err != nil {
-			return err
+			panic(err)
 		}
 
-		os.Stdout.Write(jsonData)
-		os.Stdout.Write([]byte("\n"))
+		ReportMessage(fi, slog.LevelInfo, "diff base tag", "tag", fi.diffBase)
 	}
 
-	return nil
-}
+	if len(fi.diffBase) > 0 {
+		ReportPhase(fi, "finding changed lines")
 
-func WriteData(fi *FlagInfo, authorData AuthorData) error {
-	var err error
-	if fi.format == "tabular" {
-		err = WriteTabular(authorData)
-	} else if fi.format == "csv" {
-		err = WriteCSV(authorData)
-	} else if fi.format == "json" {
-		err = WriteJSON(authorData)
-	} else if fi.format == "json-lines" {
-		err = WriteJSONLines(authorData)
+		err = FindDiffHunks(fi)
+		if err != nil {
+			panic(err)
+		}
 	}
-	return err
-}
 
-func main() {
-	os.Stderr.WriteString("starting\n")
+	if len(fi.explainFile) > 0 {
+		ReportPhase(fi, "explaining file")
 
-	os.Stderr.WriteString("parsing flags\n")
+		err = ExplainFile(fi, fi.explainFile)
+		if err != nil {
+			panic(err)
+		}
 
-	fi, err := ParseFlag()
-	if err != nil {
-		panic(err)
+		ReportPhase(fi, "done")
+		return
 	}
 
-	os.Stderr.WriteString("parsing extensions and languages\n")
+	ReportPhase(fi, "parsing extensions and languages")
 
 	ei, err := ParseExtension(fi)
 	if err != nil {
 		panic(err)
 	}
 
-	os.Stderr.WriteString("finding files\n")
+	if len(fi.timeseries) > 0 {
+		ReportPhase(fi, "writing time series")
+
+		err = WriteTimeseries(fi, ei)
+		if err != nil {
+			panic(err)
+		}
+
+		ReportPhase(fi, "done")
+		return
+	}
+
+	ReportPhase(fi, "finding files")
 
 	files, err := FindFiles(fi, ei)
 	if err != nil {
 		panic(err)
 	}
 
-	os.Stderr.WriteString("collecting statistics\n")
+	if fi.percentBase == "repo" {
+		ReportPhase(fi, "counting repository lines")
 
-	authorData, err := CollectStatistics(fi, files)
-	if err != nil {
-		panic(err)
+		err = CountRepoLines(fi)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	os.Stderr.WriteString("sorting data\n")
+	if len(fi.baseResult) > 0 {
+		ReportPhase(fi, "loading base result")
 
-	SortData(fi, authorData)
+		err = ReadSavedResult(fi)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-	os.Stderr.WriteString("writing data\n")
+	ReportPhase(fi, "collecting statistics")
 
-	err = WriteData(fi, authorData)
+	authorData, fileData, err := CollectStatistics(fi, files)
 	if err != nil {
 		panic(err)
 	}
 
-	os.Stderr.WriteString("done\n")
+	if len(fi.saveResult) > 0 {
+		ReportPhase(fi, "saving result")
+
+		err = WriteSavedResult(fi)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if fi.verify {
+		ReportPhase(fi, "verifying line counts")
+
+		err = VerifyLines(fi, fileData)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	WriteReports(fi, authorData, fileData)
 }